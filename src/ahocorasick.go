@@ -0,0 +1,67 @@
+package main
+
+// ahoCorasick is an automaton finding all occurrences of a set of lower case ASCII keywords in a text in a single
+// pass, ASCII letters of the text are matched case insensitively
+type ahoCorasick struct {
+	next    [][256]int32 // transitions of nodes completed with failure links
+	outputs [][]int      // indexes of keywords ending at a node
+}
+
+func newAhoCorasick(keywords []string) *ahoCorasick {
+	ac := &ahoCorasick{next: make([][256]int32, 1), outputs: make([][]int, 1)}
+
+	for idx, keyword := range keywords {
+		node := int32(0)
+		for pos := 0; pos < len(keyword); pos++ {
+			c := keyword[pos]
+			if ac.next[node][c] == 0 {
+				ac.next = append(ac.next, [256]int32{})
+				ac.outputs = append(ac.outputs, nil)
+				ac.next[node][c] = int32(len(ac.next) - 1)
+			}
+			node = ac.next[node][c]
+		}
+		ac.outputs[node] = append(ac.outputs[node], idx)
+	}
+
+	// breadth first traversal computing failure links, missing transitions are replaced with transitions
+	// of failure nodes so that matching needs a single lookup per byte
+	fail := make([]int32, len(ac.next))
+	var queue []int32
+	for c := 0; c < 256; c++ {
+		if node := ac.next[0][c]; node != 0 {
+			queue = append(queue, node)
+		}
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		ac.outputs[node] = append(ac.outputs[node], ac.outputs[fail[node]]...)
+
+		for c := 0; c < 256; c++ {
+			if child := ac.next[node][c]; child != 0 {
+				fail[child] = ac.next[fail[node]][c]
+				queue = append(queue, child)
+			} else {
+				ac.next[node][c] = ac.next[fail[node]][c]
+			}
+		}
+	}
+	return ac
+}
+
+// scan calls found for every occurrence of a keyword in a text
+func (ac *ahoCorasick) scan(text string, found func(keyword int)) {
+	node := int32(0)
+	for pos := 0; pos < len(text); pos++ {
+		c := text[pos]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		node = ac.next[node][c]
+		for _, keyword := range ac.outputs[node] {
+			found(keyword)
+		}
+	}
+}
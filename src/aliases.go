@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Aliases maps scanned files to hard links and copies of them that are not scanned, it is updated concurrently
+// by walkers of directories
+type Aliases struct {
+	mutex  sync.Mutex
+	inodes map[fileKey]string  // first found path of every hard linked file, of every file when symlinks are followed
+	paths  map[string][]string // scanned path mapped to paths with the same content
+	sizes  map[string]int64    // sizes of found files, tracked only when identical files are looked for
+}
+
+func newAliases() *Aliases {
+	return &Aliases{inodes: map[fileKey]string{}, paths: map[string][]string{}}
+}
+
+// addLink records a hard linked file or a file reached via symlinks, false is returned when another link of the file
+// was already found
+func (a *Aliases) addLink(key fileKey, path string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if first, ok := a.inodes[key]; ok {
+		a.paths[first] = append(a.paths[first], path)
+		return false
+	}
+	a.inodes[key] = path
+	return true
+}
+
+// trackSizes enables recording of sizes of found files
+func (a *Aliases) trackSizes() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.sizes == nil {
+		a.sizes = map[string]int64{}
+	}
+}
+
+// addSize records a size of a found file when sizes are tracked
+func (a *Aliases) addSize(path string, size int64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.sizes != nil {
+		a.sizes[path] = size
+	}
+}
+
+// size returns a recorded size of a found file
+func (a *Aliases) size(path string) (int64, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	size, ok := a.sizes[path]
+	return size, ok
+}
+
+// dropLinks removes hard links of files that are not scanned, e.g. binaries, and records them as skipped for the same
+// reasons as the first found links
+func (a *Aliases) dropLinks(files []string, skipped *Skips) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	scanned := make(map[string]bool, len(files))
+	for _, file := range files {
+		scanned[file] = true
+	}
+
+	var reasons map[string]string
+	for path, links := range a.paths {
+		if scanned[path] {
+			continue
+		}
+		if reasons == nil {
+			reasons = skipped.reasons()
+		}
+		reason, ok := reasons[path]
+		if !ok {
+			reason = skipUnreadable
+		}
+		for _, link := range links {
+			skipped.add(link, reason)
+		}
+		delete(a.paths, path)
+	}
+}
+
+// add records a path with the same content as a scanned path, aliases of the former are moved to the latter
+func (a *Aliases) add(path string, alias string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.paths[path] = append(append(a.paths[path], alias), a.paths[alias]...)
+	delete(a.paths, alias)
+}
+
+// Get returns sorted paths with the same content as a scanned path
+func (a *Aliases) Get(path string) []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	aliases := append([]string{}, a.paths[path]...)
+	sort.Strings(aliases)
+	return aliases
+}
+
+// Num returns the number of files that are not scanned since they have the same content as scanned files
+func (a *Aliases) Num() (count int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, aliases := range a.paths {
+		count += len(aliases)
+	}
+	return count
+}
+
+// size of heads of files of equal sizes hashed first, only files with equal heads are hashed whole
+const dedupHeadSize = 64 << 10
+
+// hashFile returns a SHA-256 hash of content of a file, limited to a number of its first bytes unless limit is 0
+func (app *App) hashFile(file string, limit int64) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	f, err := os.Open(file)
+	if err != nil {
+		return sum, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var reader io.Reader = f
+	if app.readLimiter != nil {
+		reader = throttledReader{reader: f, limiter: app.readLimiter}
+	}
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit)
+	}
+
+	hash := sha256.New()
+	if _, err = io.Copy(hash, reader); err != nil {
+		return sum, err
+	}
+	copy(sum[:], hash.Sum(nil))
+	return sum, nil
+}
+
+// hashFiles hashes files of a list given by their indexes concurrently, hashes of files that cannot be read are nil
+func (app *App) hashFiles(files []string, indexes []int, limit int64, hashes []*[sha256.Size]byte) {
+	jobs := make(chan int, *app.maxNumberOfCpu)
+	var wg sync.WaitGroup
+	for cnt := 0; cnt < cap(jobs); cnt++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if sum, err := app.hashFile(files[idx], limit); err == nil {
+					hashes[idx] = &sum
+				}
+			}
+		}()
+	}
+	for _, idx := range indexes {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// contentKey groups files that may have the same content, by sizes and hashes of heads
+type contentKey struct {
+	size int64
+	head [sha256.Size]byte
+}
+
+// duplicates returns sorted indexes of files sharing a key with other files
+func duplicates(groups map[contentKey][]int) []int {
+	var indexes []int
+	for _, group := range groups {
+		if len(group) > 1 {
+			indexes = append(indexes, group...)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// dedupContent removes files with the same content as an earlier file of a list, which become its aliases. Only
+// files with sizes equal to sizes of other files are hashed, first their heads and then whole files with equal heads.
+// Files above the size limit, whose tails are scanned, are not hashed.
+func (app *App) dedupContent(files []string) []string {
+	sizes := map[contentKey][]int{}
+	for idx, file := range files {
+		size, ok := app.aliases.size(file)
+		if !ok || (*app.maxSize > 0 && size > int64(*app.maxSize)<<20) {
+			continue
+		}
+		key := contentKey{size: size}
+		sizes[key] = append(sizes[key], idx)
+	}
+
+	hashes := make([]*[sha256.Size]byte, len(files))
+	candidates := duplicates(sizes)
+	app.hashFiles(files, candidates, dedupHeadSize, hashes)
+
+	// heads of files not larger than heads are their whole contents
+	heads := map[contentKey][]int{}
+	for _, idx := range candidates {
+		if size, _ := app.aliases.size(files[idx]); hashes[idx] != nil && size > dedupHeadSize {
+			key := contentKey{size: size, head: *hashes[idx]}
+			heads[key] = append(heads[key], idx)
+			hashes[idx] = nil
+		}
+	}
+	app.hashFiles(files, duplicates(heads), 0, hashes)
+
+	first := map[[sha256.Size]byte]string{}
+	unique := make([]string, 0, len(files))
+	for idx, file := range files {
+		if hashes[idx] != nil {
+			if path, ok := first[*hashes[idx]]; ok {
+				app.aliases.add(path, file)
+				continue
+			}
+			first[*hashes[idx]] = file
+		}
+		unique = append(unique, file)
+	}
+	return unique
+}
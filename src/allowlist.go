@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// default global stopwords, matches containing any of them are considered benign
+var defaultStopwords = []string{"example", "dummy", "xxxx", "placeholder"}
+
+// compileAllowlists compiles allow regexes of a pattern
+func (p *Pattern) compileAllowlists() error {
+	p.allowRegexes = nil
+	for _, allow := range p.Allow {
+		reg, err := regexp.Compile(allow)
+		if err != nil {
+			return fmt.Errorf("pattern %q has invalid allow regex %q: %s", p.Name, allow, err.Error())
+		}
+		p.allowRegexes = append(p.allowRegexes, reg)
+	}
+
+	p.allowPathRegexes = nil
+	for _, allow := range p.AllowPaths {
+		reg, err := regexp.Compile(allow)
+		if err != nil {
+			return fmt.Errorf("pattern %q has invalid allow_paths regex %q: %s", p.Name, allow, err.Error())
+		}
+		p.allowPathRegexes = append(p.allowPathRegexes, reg)
+	}
+	return nil
+}
+
+// allowed checks whether a match of a pattern is known to be benign based on allowlists of the pattern
+func (p *Pattern) allowed(match string, line string, file string) bool {
+	for _, reg := range p.allowPathRegexes {
+		if reg.MatchString(file) {
+			return true
+		}
+	}
+
+	for _, reg := range p.allowRegexes {
+		if reg.MatchString(match) || reg.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStopword checks whether a match contains any of global stopwords
+func (app *App) isStopword(match string) bool {
+	lower := strings.ToLower(match)
+	for _, word := range app.stopwords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// suppressed checks whether a match should be dropped and counts dropped matches
+func (app *App) suppressed(pattern *Pattern, match string, line string, file string) bool {
+	if pattern.allowed(match, line, file) || app.isStopword(match) {
+		app.stats.addSuppressed(1)
+		return true
+	}
+	return false
+}
+
+// verifyStopwords builds the list of global stopwords from a comma separated list or a file
+func (app *App) verifyStopwords() {
+	var words []string
+
+	switch {
+	case len(*app.stopwordsFlag) == 0:
+		words = defaultStopwords
+	case *app.stopwordsFlag == "none":
+		words = nil
+	default:
+		words = readListOrFile(*app.stopwordsFlag, "stopwords")
+	}
+
+	app.stopwords = nil
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); len(word) > 0 {
+			app.stopwords = append(app.stopwords, word)
+		}
+	}
+}
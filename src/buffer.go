@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// default size in MiB of files above which files are scanned in chunks in buffered mode
+const defaultBufferMax = 64
+
+// size of chunks of large files scanned in buffered mode
+const chunkSize = 8 << 20
+
+// a chunk is scanned together with this number of bytes of the following chunk, so that matches starting
+// in a chunk and crossing its end are found
+const chunkOverlap = 64 << 10
+
+// lineIndex holds offsets of beginnings of lines of a buffer
+type lineIndex []int
+
+func newLineIndex(text string) lineIndex {
+	index := lineIndex{0}
+	for pos := 0; pos < len(text); {
+		next := strings.IndexByte(text[pos:], '\n')
+		if next < 0 {
+			break
+		}
+		pos += next + 1
+		index = append(index, pos)
+	}
+	return index
+}
+
+// position converts an offset in a buffer to a zero based line and a one based column
+func (index lineIndex) position(offset int) (int, int) {
+	line := sort.Search(len(index), func(idx int) bool { return index[idx] > offset }) - 1
+	return line, offset - index[line] + 1
+}
+
+// text returns a line of a buffer without the line terminator
+func (index lineIndex) text(buffer string, line int) string {
+	end := len(buffer)
+	if line+1 < len(index) {
+		end = index[line+1] - 1
+	}
+	return strings.TrimSuffix(buffer[index[line]:end], "\r")
+}
+
+// bufferMatch is the first accepted match of patterns starting in a line of a buffer
+type bufferMatch struct {
+	pattern *Pattern
+	match   string
+	column  int
+}
+
+// blockScanner scans a file block by block, a block is a whole file or a chunk of it
+type blockScanner struct {
+	app      *App
+	file     string
+	line     int               // number of the first line of the next block
+	previous *IgnoreAnnotation // annotation found in the last line of the previous block
+	timedOut bool              // matching of a block timed out, the file is recorded as partially scanned once
+	secrets  map[int]Secret
+}
+
+func (app *App) newBlockScanner(file string) *blockScanner {
+	return &blockScanner{app: app, file: file, line: 1, secrets: map[int]Secret{}}
+}
+
+// scanBuffer runs patterns over a whole buffer and returns the first accepted match of every line, matches of
+// patterns evaluated earlier take precedence like in the line by line scan. Matches starting at or after owned
+// offset belong to the next block. timedOut reports that matches of a backtracking regex are missing.
+func (app *App) scanBuffer(file string, text string, owned int, index lineIndex) (found map[int]bufferMatch, timedOut bool) {
+	found = map[int]bufferMatch{}
+
+	var selected []bool
+	if app.prefilter != nil {
+		selected = app.prefilter.candidates(text)
+	}
+	if app.combined != nil {
+		selected = app.combined.filter(text, selected)
+	}
+
+	for idx, pattern := range app.patterns.Get() {
+		if (selected != nil && !selected[idx]) || !pattern.appliesTo(file) {
+			continue
+		}
+
+		// the first match of a pattern in a line passing validation and allowlists is reported, like in the line by
+		// line scan
+		locs, err := findAllIndex(pattern.bufferRegex, text)
+		if err != nil {
+			timedOut = true
+		}
+		for _, loc := range locs {
+			if loc[0] >= owned {
+				break
+			}
+
+			// a secret is reported in the line where it starts, which differs from the line where the match
+			// starts when the secret is a group of a multi-line match
+			group := pattern.group(len(loc)/2-1, func(group int) bool { return loc[2*group] >= loc[2*group+1] })
+			start, end := loc[2*group], loc[2*group+1]
+			if start < 0 {
+				continue
+			}
+
+			// \s and negated classes match line breaks, which are not a part of a secret at its end
+			match := strings.TrimRight(text[start:end], "\r\n")
+			if len(match) == 0 {
+				continue
+			}
+
+			line, column := index.position(start)
+			if _, ok := found[line]; ok || start >= owned {
+				continue
+			}
+
+			if pattern.Entropy > 0 && shannonEntropy(match) < pattern.Entropy {
+				continue
+			}
+			if pattern.discards() && !pattern.validate(match) {
+				continue
+			}
+			if app.suppressed(&pattern, match, index.text(text, line), file) {
+				continue
+			}
+
+			matched := pattern
+			found[line] = bufferMatch{pattern: &matched, match: strings.Clone(match), column: column}
+		}
+
+		app.throttle(len(text))
+	}
+	return found, timedOut
+}
+
+// scanBlock scans lines of a block starting before owned offset, lines after it are scanned with the next block
+func (s *blockScanner) scanBlock(text string, owned int) {
+	index := newLineIndex(text)
+	found, timedOut := s.app.scanBuffer(s.file, text, owned, index)
+	if timedOut && !s.timedOut {
+		s.timedOut = true
+		s.app.skipped.add(s.file, skipTimeout)
+	}
+
+	for line := 0; line < len(index) && index[line] < owned; line++ {
+		lineText := index.text(text, line)
+		number := s.line + line
+
+		annotation := parseIgnoreAnnotation(lineText)
+		if annotation != nil {
+			s.app.stats.addAnnotations(1)
+		}
+
+		var secret Secret
+		match, ok := found[line]
+		if ok {
+			secret = newSecret(match.pattern, match.match, number)
+			secret.Column = match.column
+		} else {
+			secret, ok = s.app.scanForHash(s.file, lineText, number)
+		}
+		if !ok {
+			if pattern, match, chain := s.app.scanDecoded(s.file, lineText, *s.app.decodeDepth, nil); pattern != nil {
+				secret, ok = newSecret(pattern, match, number), true
+				secret.Encoding = strings.Join(chain, " > ")
+			}
+		}
+
+		if ok {
+			if annotation.ignores(secret) || s.previous.ignores(secret) {
+				s.app.stats.addIgnored(1)
+			} else {
+				s.secrets[number] = secret
+			}
+		}
+		s.previous = annotation
+	}
+
+	s.line += strings.Count(text[:owned], "\n")
+	s.app.stats.addScanned(int64(owned))
+}
+
+// bufferText returns a string sharing memory with a buffer to avoid copying whole files, the buffer must not be
+// modified afterwards
+func bufferText(buffer []byte) string {
+	return unsafe.String(unsafe.SliceData(buffer), len(buffer))
+}
+
+// scanFileBuffered scans a whole file at once, files larger than the threshold are scanned in chunks
+func (app *App) scanFileBuffered(file string, f *os.File, reader io.Reader) map[int]Secret {
+	scanner := app.newBlockScanner(file)
+
+	if info, err := f.Stat(); err == nil && info.Size() <= int64(*app.bufferMax)<<20 {
+		// the buffer is allocated upfront, growing it would hold more copies of the file
+		buffer := bytes.NewBuffer(make([]byte, 0, info.Size()+bytes.MinRead))
+		if _, err := buffer.ReadFrom(reader); err != nil {
+			log.Println(err.Error())
+			return nil
+		}
+		data := buffer.Bytes()
+		scanner.scanBlock(bufferText(data), len(data))
+		return scanner.secrets
+	}
+
+	var block []byte
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		block = append(block, chunk[:n]...)
+
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				log.Println(err.Error())
+			}
+			scanner.scanBlock(bufferText(block), len(block))
+			return scanner.secrets
+		}
+
+		// lines starting in the overlap at the end of the block are scanned with the next block
+		owned := len(block)
+		if len(block) > chunkOverlap {
+			if pos := bytes.LastIndexByte(block[:len(block)-chunkOverlap], '\n'); pos >= 0 {
+				owned = pos + 1
+			}
+		}
+
+		// the next block is a new buffer, since strings of secrets found in the block may share its memory
+		scanner.scanBlock(bufferText(block), owned)
+		block = append([]byte{}, block[owned:]...)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroup v1 memory limits at or above this value mean no limit
+const cgroupUnlimitedMemory = 1 << 62
+
+// CgroupLimits describes CPU and memory limits of the cgroup of the process
+type CgroupLimits struct {
+	Version     int     // 1 or 2, 0 when cgroups are not available
+	CPUQuota    float64 // number of vCPUs, 0 when unlimited
+	MemoryLimit int64   // bytes, 0 when unlimited
+	usageFile   string  // file with cumulative CPU usage of the cgroup
+}
+
+// cgroupPaths parses /proc/self/cgroup and returns paths of the process cgroups for every controller,
+// the cgroup v2 path is stored under an empty controller name
+func cgroupPaths() map[string]string {
+	paths := map[string]string{}
+
+	fd, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return paths
+	}
+	defer func() { _ = fd.Close() }()
+
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = fields[2]
+		}
+	}
+	return paths
+}
+
+// cgroupFile finds a file of a cgroup, in containers the cgroup of the process is usually mounted as the root
+func cgroupFile(mounts []string, path string, name string) string {
+	for _, mount := range mounts {
+		for _, dir := range []string{filepath.Join(cgroupRoot, mount, path), filepath.Join(cgroupRoot, mount)} {
+			if file := filepath.Join(dir, name); fileExists(file) {
+				return file
+			}
+		}
+	}
+	return ""
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+func readCgroupValue(file string) string {
+	if len(file) == 0 {
+		return ""
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// detectCgroupLimits reads CPU quota and memory limit of cgroup v2 or v1 of the process
+func detectCgroupLimits() CgroupLimits {
+	var limits CgroupLimits
+	paths := cgroupPaths()
+
+	if path, ok := paths[""]; ok && fileExists(filepath.Join(cgroupRoot, "cgroup.controllers")) {
+		limits.Version = 2
+
+		// cpu.max contains a quota and a period in microseconds, the quota is max when unlimited
+		if fields := strings.Fields(readCgroupValue(cgroupFile([]string{""}, path, "cpu.max"))); len(fields) == 2 {
+			quota, errQuota := strconv.ParseFloat(fields[0], 64)
+			period, errPeriod := strconv.ParseFloat(fields[1], 64)
+			if errQuota == nil && errPeriod == nil && period > 0 {
+				limits.CPUQuota = quota / period
+			}
+		}
+
+		if limit, err := strconv.ParseInt(readCgroupValue(cgroupFile([]string{""}, path, "memory.max")), 10, 64); err == nil {
+			limits.MemoryLimit = limit
+		}
+
+		limits.usageFile = cgroupFile([]string{""}, path, "cpu.stat")
+		return limits
+	}
+
+	if len(paths) == 0 {
+		return limits
+	}
+	limits.Version = 1
+
+	cpuMounts := []string{"cpu,cpuacct", "cpu", "cpuacct,cpu"}
+	quota, errQuota := strconv.ParseFloat(readCgroupValue(cgroupFile(cpuMounts, paths["cpu"], "cpu.cfs_quota_us")), 64)
+	period, errPeriod := strconv.ParseFloat(readCgroupValue(cgroupFile(cpuMounts, paths["cpu"], "cpu.cfs_period_us")), 64)
+	if errQuota == nil && errPeriod == nil && quota > 0 && period > 0 {
+		limits.CPUQuota = quota / period
+	}
+
+	limit, err := strconv.ParseInt(readCgroupValue(cgroupFile([]string{"memory"}, paths["memory"], "memory.limit_in_bytes")), 10, 64)
+	if err == nil && limit < cgroupUnlimitedMemory {
+		limits.MemoryLimit = limit
+	}
+
+	limits.usageFile = cgroupFile([]string{"cpu,cpuacct", "cpuacct", "cpuacct,cpu"}, paths["cpuacct"], "cpuacct.usage")
+	return limits
+}
+
+// CPUs returns the number of vCPUs available to the process
+func (limits CgroupLimits) CPUs() int {
+	cpus := runtime.NumCPU()
+	if limits.CPUQuota > 0 && int(math.Ceil(limits.CPUQuota)) < cpus {
+		cpus = int(math.Ceil(limits.CPUQuota))
+	}
+	return cpus
+}
+
+// cpuUsage returns cumulative CPU time used by the cgroup
+func (limits CgroupLimits) cpuUsage() (time.Duration, bool) {
+	value := readCgroupValue(limits.usageFile)
+
+	if limits.Version == 2 {
+		for _, line := range strings.Split(value, "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "usage_usec" {
+				usage, err := strconv.ParseInt(fields[1], 10, 64)
+				return time.Duration(usage) * time.Microsecond, err == nil
+			}
+		}
+		return 0, false
+	}
+
+	usage, err := strconv.ParseInt(value, 10, 64)
+	return time.Duration(usage), err == nil
+}
+
+// Load measures CPU usage of the cgroup during an interval as a percentage of its CPU quota, a measurement interrupted
+// by cancelling ctx fails
+func (limits CgroupLimits) Load(ctx context.Context, interval time.Duration) (float64, bool) {
+	before, ok := limits.cpuUsage()
+	if !ok || limits.CPUQuota <= 0 {
+		// a failed measurement lasts the interval too, so that callers measuring in a loop do not spin
+		sleep(ctx, interval)
+		return 0, false
+	}
+
+	if !sleep(ctx, interval) {
+		return 0, false
+	}
+	after, ok := limits.cpuUsage()
+	if !ok {
+		return 0, false
+	}
+	return float64(after-before) / (float64(interval) * limits.CPUQuota) * 100, true
+}
+
+func describeQuota(quota float64) string {
+	if quota <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%.1f vCPUs", quota)
+}
+
+func describeMemoryLimit(limit int64) string {
+	if limit <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d MiB", limit>>20)
+}
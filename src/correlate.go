@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// extensions of files that are checked for X.509 certificates
+var certificateExtensions = []string{".pem", ".crt", ".cer", ".cert"}
+
+// KeyRelation describes a certificate or an authorized key matching a found private key
+type KeyRelation struct {
+	KeyFile     string
+	KeyLine     int
+	Fingerprint string
+	Kind        string // certificate or authorized key
+	File        string
+	Line        int
+	Details     string
+}
+
+type publicKeyEntry struct {
+	file    string
+	line    int
+	kind    string
+	details string
+}
+
+func isAuthorizedKeysFile(file string) bool {
+	return strings.HasPrefix(filepath.Base(file), "authorized_keys")
+}
+
+func isCertificateFile(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	for _, certExt := range certificateExtensions {
+		if ext == certExt {
+			return true
+		}
+	}
+	return false
+}
+
+// readAuthorizedKeys returns public keys from an authorized_keys file indexed by their fingerprints
+func readAuthorizedKeys(file string, entries map[string][]publicKeyEntry) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer func() { _ = fd.Close() }()
+
+	scanner := bufio.NewScanner(fd)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		pub, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(text))
+		if err != nil {
+			continue
+		}
+
+		details := pub.Type()
+		if len(comment) > 0 {
+			details += " " + comment
+		}
+		if len(options) > 0 {
+			details += fmt.Sprintf(" (options: %s)", strings.Join(options, ","))
+		}
+
+		fp := ssh.FingerprintSHA256(pub)
+		entries[fp] = append(entries[fp], publicKeyEntry{file: file, line: line, kind: "authorized key", details: details})
+	}
+}
+
+// readCertificates returns public keys of X.509 certificates found in a file indexed by their fingerprints. Files
+// larger than maxSize, of which only heads and tails were scanned, are not read.
+func readCertificates(file string, maxSize int64, entries map[string][]publicKeyEntry) {
+	if info, err := os.Stat(file); err != nil || (maxSize > 0 && info.Size() > maxSize) {
+		return
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+
+	// lines are counted from the end of the previously counted part up to the BEGIN marker of every block
+	line, counted := 1, 0
+	for offset := 0; ; {
+		block, rest := pem.Decode(data[offset:])
+		if block == nil {
+			return
+		}
+		end := len(data) - len(rest)
+		begin := offset + bytes.LastIndex(data[offset:end], []byte("-----BEGIN "))
+		line += bytes.Count(data[counted:begin], []byte("\n"))
+		counted, offset = begin, end
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		pub, err := ssh.NewPublicKey(cert.PublicKey)
+		if err != nil {
+			continue
+		}
+
+		fp := ssh.FingerprintSHA256(pub)
+		entries[fp] = append(entries[fp], publicKeyEntry{file: file, line: line, kind: "certificate", details: describeCertificate(cert)})
+	}
+}
+
+func describeCertificate(cert *x509.Certificate) string {
+	var san []string
+	san = append(san, cert.DNSNames...)
+	san = append(san, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		san = append(san, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		san = append(san, uri.String())
+	}
+
+	details := fmt.Sprintf("subject: %s", cert.Subject.String())
+	if len(san) > 0 {
+		details += fmt.Sprintf(", SAN: %s", strings.Join(san, ","))
+	}
+	details += fmt.Sprintf(", expires: %s", cert.NotAfter.Format(time.DateOnly))
+	if time.Now().After(cert.NotAfter) {
+		details += " (expired)"
+	}
+	return details
+}
+
+// Correlate matches public keys of found private keys with certificates and authorized keys in scanned files
+func (app *App) Correlate(files []string, scans []*ScanResults) []KeyRelation {
+	var relations []KeyRelation
+	var keys int
+
+	for _, scan := range scans {
+		for _, secret := range scan.secrets {
+			if secret.Key != nil && len(secret.Key.Fingerprint) > 0 {
+				keys++
+			}
+		}
+	}
+
+	if keys == 0 {
+		return nil
+	}
+
+	fmt.Printf("[*] Correlating %d private keys with certificates and authorized keys\n", keys)
+
+	maxSize := int64(*app.maxSize) << 20
+	entries := map[string][]publicKeyEntry{}
+	for _, file := range files {
+		if isAuthorizedKeysFile(file) {
+			readAuthorizedKeys(file, entries)
+		} else if isCertificateFile(file) {
+			readCertificates(file, maxSize, entries)
+		}
+	}
+
+	// a certificate can be bundled with its key in the same file
+	for _, scan := range scans {
+		if !isCertificateFile(scan.file) {
+			readCertificates(scan.file, maxSize, entries)
+		}
+	}
+
+	for _, scan := range scans {
+		for _, secret := range scan.secrets {
+			if secret.Key == nil || len(secret.Key.Fingerprint) == 0 {
+				continue
+			}
+
+			for _, entry := range entries[secret.Key.Fingerprint] {
+				relations = append(relations, KeyRelation{
+					KeyFile:     scan.file,
+					KeyLine:     secret.LineNumber,
+					Fingerprint: secret.Key.Fingerprint,
+					Kind:        entry.kind,
+					File:        entry.file,
+					Line:        entry.line,
+					Details:     entry.details,
+				})
+			}
+		}
+	}
+
+	// secrets of a scan are kept in a map, relations are sorted to report them in the same order in every run
+	sort.Slice(relations, func(i, j int) bool {
+		a, b := relations[i], relations[j]
+		if a.KeyFile != b.KeyFile {
+			return a.KeyFile < b.KeyFile
+		}
+		if a.KeyLine != b.KeyLine {
+			return a.KeyLine < b.KeyLine
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	fmt.Printf("[+] Found %d certificates and authorized keys matching private keys\n", len(relations))
+	return relations
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestReadCertificatesLines(t *testing.T) {
+	cert := testCertificate(t)
+	other := string(pem.EncodeToMemory(&pem.Block{Type: "OTHER", Bytes: []byte("other")}))
+	// certificates start after a comment, after another block and after text following another certificate
+	text := "# bundle\n\n" + cert + other + "\n" + cert + "text\n\n" + cert
+	certLines := strings.Count(cert, "\n")
+	want := []int{3, 3 + certLines + strings.Count(other, "\n") + 1, 0}
+	want[2] = want[1] + certLines + 2
+
+	file := filepath.Join(t.TempDir(), "bundle.pem")
+	if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := map[string][]publicKeyEntry{}
+	readCertificates(file, 0, entries)
+	if len(entries) != 1 {
+		t.Fatalf("found %d fingerprints, want 1", len(entries))
+	}
+	for _, found := range entries {
+		if len(found) != len(want) {
+			t.Fatalf("found %d certificates, want %d", len(found), len(want))
+		}
+		for idx, entry := range found {
+			if entry.line != want[idx] {
+				t.Errorf("certificate %d found in line %d, want %d", idx, entry.line, want[idx])
+			}
+		}
+	}
+
+	// files above the size limit were scanned only partially and are not read
+	entries = map[string][]publicKeyEntry{}
+	readCertificates(file, int64(len(text)-1), entries)
+	if len(entries) != 0 {
+		t.Errorf("found %d fingerprints in a file above the size limit, want 0", len(entries))
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// minimum length of an encoded substring to be considered for decoding
+const minEncodedLength = 16
+
+// minimum ratio of printable characters in decoded data to treat it as plausible text
+const minPrintableRatio = 0.9
+
+type decoder struct {
+	name   string
+	regex  *regexp.Regexp
+	decode func(string) ([]byte, error)
+}
+
+var decoders = []decoder{
+	{name: "hex", regex: regexp.MustCompile(`(?:[0-9a-fA-F]{2}){8,}`), decode: hex.DecodeString},
+	{name: "base64", regex: regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`), decode: decodeBase64},
+	{name: "url", regex: regexp.MustCompile(`[^\s%]*(?:%[0-9a-fA-F]{2}[^\s%]*){3,}`), decode: decodePercent},
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	var err error
+	var data []byte
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if data, err = encoding.DecodeString(s); err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+func decodePercent(s string) ([]byte, error) {
+	data, err := url.PathUnescape(s)
+	return []byte(data), err
+}
+
+// isPlausibleText checks whether decoded data looks like text worth scanning
+func isPlausibleText(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+
+	var printable, total int
+	for _, r := range string(data) {
+		total++
+		if r == '\n' || r == '\r' || r == '\t' || (r >= ' ' && r != 0x7f) {
+			printable++
+		}
+	}
+	return float64(printable)/float64(total) >= minPrintableRatio
+}
+
+// scanDecoded looks for encoded substrings in a text, decodes them and scans decoded data with secret patterns.
+// Decoding is repeated on decoded data until depth is exhausted. It returns a found pattern, the match and
+// the chain of decodings that led to the match.
+func (app *App) scanDecoded(file string, text string, depth int, chain []string) (*Pattern, string, []string) {
+	if depth <= 0 || len(text) < minEncodedLength {
+		return nil, "", nil
+	}
+
+	for _, dec := range decoders {
+		for _, encoded := range dec.regex.FindAllString(text, -1) {
+			if len(encoded) < minEncodedLength {
+				continue
+			}
+
+			data, err := dec.decode(encoded)
+			if err != nil || !isPlausibleText(data) || string(data) == encoded {
+				continue
+			}
+
+			decChain := append(append([]string{}, chain...), dec.name)
+			for _, line := range strings.Split(string(data), "\n") {
+				if pattern, match := app.scanWithRegex(file, line); pattern != nil {
+					return pattern, match, decChain
+				}
+
+				if pattern, match, subChain := app.scanDecoded(file, line, depth-1, decChain); pattern != nil {
+					return pattern, match, subChain
+				}
+			}
+		}
+	}
+	return nil, "", nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestScanDecoded(t *testing.T) {
+	patterns := &Patterns{}
+	if err := patterns.read(`
+- name: Test token
+  regex: "tok_[a-z0-9]{16}"
+`); err != nil {
+		t.Fatal(err)
+	}
+	forced := true
+	app := &App{patterns: patterns, forceFlg: &forced}
+
+	const secret = "tok_0123456789abcdef"
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	hx := func(s string) string { return hex.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name  string
+		text  string
+		depth int
+		chain []string // nil when nothing is found
+	}{
+		{"base64", "value: " + b64("key = "+secret), 1, []string{"base64"}},
+		{"unpadded base64url", base64.RawURLEncoding.EncodeToString([]byte("key=" + secret + "?")), 1, []string{"base64"}},
+		{"url", "q=" + url.PathEscape("k=\""+secret+"\" & <x>"), 1, []string{"url"}},
+		// hex strings are matched by the base64 regex as well, hex is tried first so they are reported as hex
+		{"hex before base64", "data " + hx(secret), 1, []string{"hex"}},
+		{"nested", b64("x = " + hx(secret)), 2, []string{"base64", "hex"}},
+		{"nested too deep", b64("x = " + hx(secret)), 1, nil},
+		{"base64 twice", b64(b64(secret)), 2, []string{"base64", "base64"}},
+		{"decoding disabled", b64(secret), 0, nil},
+		{"binary data", b64("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09" + secret), 1, nil},
+		{"plain text", "no encoded " + secret, 1, nil},
+		{"short", "dG9rXzA=", 1, nil},
+	}
+
+	for _, test := range tests {
+		pattern, match, chain := app.scanDecoded("", test.text, test.depth, nil)
+		if test.chain == nil {
+			if pattern != nil {
+				t.Errorf("%s: found %q decoded with %v, want nothing", test.name, match, chain)
+			}
+			continue
+		}
+		if pattern == nil || match != secret || !reflect.DeepEqual(chain, test.chain) {
+			t.Errorf("%s: found %q decoded with %v, want %q decoded with %v", test.name, match, chain, secret, test.chain)
+		}
+	}
+}
+
+func TestIsPlausibleText(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{"", false},
+		{"plain text\n\twith tabs", true},
+		{"zażółć gęślą jaźń", true},
+		{"\xff\xfe invalid UTF-8", false},
+		{"\x00\x01\x02 mostly binary", false},
+		{"one control\x01 character in a longer text", true},
+	}
+	for _, test := range tests {
+		if got := isPlausibleText([]byte(test.data)); got != test.want {
+			t.Errorf("isPlausibleText(%q) = %t, want %t", test.data, got, test.want)
+		}
+	}
+}
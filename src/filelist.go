@@ -3,32 +3,55 @@ package main
 import (
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/schollz/progressbar/v3"
-	"io/fs"
 	"log"
-	"path/filepath"
 	"regexp"
 	"runtime"
 	"sync"
+	"time"
 )
 
+// FileListOptions control discovery of files
+type FileListOptions struct {
+	excludes []string // regular expressions of excluded directories
+	maxSize  int64    // size in bytes above which files are too large, 0 means unlimited
+	headTail bool     // too large files are kept since only their heads and tails are scanned
+	guard    *MemoryGuard
+	skipped  *Skips
+	aliases  *Aliases // hard links of found files
+
+	oneFilesystem  bool              // directories on other file systems than a root are skipped
+	maxDepth       int               // depth of directories below a root that are not walked, 0 means unlimited
+	followSymlinks bool              // symlinks to files and directories are followed
+	pseudo         map[uint64]string // devices of pseudo file systems mapped to their mount points
+	walkers        int               // number of goroutines walking directories concurrently, 1 or less walks sequentially
+}
+
+// tooLarge checks whether a file is skipped due to its size
+func (options *FileListOptions) tooLarge(size int64) bool {
+	return options.maxSize > 0 && size > options.maxSize && !options.headTail
+}
+
 // workers will use mimetype to determine a file type and decide whether to collect it
-func worker(id int, wg *sync.WaitGroup, jobs chan string, results chan string) {
+func worker(id int, wg *sync.WaitGroup, jobs chan string, results chan string, skipped *Skips) {
 	defer wg.Done()
 	//defer close(results)
 
 	for fp := range jobs {
 		fm, err := mimetype.DetectFile(fp)
-
-		if fm.Is("application/octet-stream") {
+		if err != nil {
+			skipped.addError(fp, err)
 			continue
 		}
 
-		if err == nil {
-			for mtype := fm; mtype != nil; mtype = mtype.Parent() {
-				if mtype.Is("text/plain") {
-					results <- fp
-				}
-			}
+		text := false
+		for mtype := fm; mtype != nil && !text; mtype = mtype.Parent() {
+			text = mtype.Is("text/plain")
+		}
+
+		if text {
+			results <- fp
+		} else {
+			skipped.add(fp, skipBinary)
 		}
 	}
 }
@@ -57,20 +80,20 @@ func isExcludedRegEx(path string, patterns []string) (excluded bool) {
 	return excluded
 }
 
-func getFileList(directory string, paths2exclude []string) (files []string, excludedPaths []string) {
+// getFileList finds plain text files in a directory, skipped files are recorded with their reasons in options.
+// The time of walking the directory is returned as well, types of files are detected concurrently and take longer.
+func getFileList(directory string, options *FileListOptions) (files []string, walked time.Duration) {
 	var wg sync.WaitGroup
 	var results chan string = make(chan string, 1000)
-	var excluded chan string = make(chan string, 100)
 	var jobs chan string = make(chan string, runtime.NumCPU())
 
 	files = []string{}
-	excludedPaths = []string{}
 
 	bar := progressbar.Default(-1, "Finding plaintext files")
 
 	for cnt := 0; cnt < cap(jobs); cnt++ {
 		wg.Add(1)
-		go worker(cnt, &wg, jobs, results)
+		go worker(cnt, &wg, jobs, results, options.skipped)
 	}
 
 	var rg sync.WaitGroup // results WaitGroup
@@ -85,51 +108,25 @@ func getFileList(directory string, paths2exclude []string) (files []string, excl
 		}
 	}()
 
-	var ex sync.WaitGroup
-	ex.Add(1)
-
-	go func() {
-		defer ex.Done()
-
-		for ep := range excluded {
-			excludedPaths = append(excludedPaths, ep)
-		}
-	}()
-
 	// this goroutine walks through file systems and feeds workers with found files
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		// since walking a file system has been completed signal workers that job has been finished
 		defer close(jobs)
-		defer close(excluded)
-
-		_ = filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return nil
-			}
-
-			if d.IsDir() && isExcludedRegEx(path, paths2exclude) {
-				excluded <- path
-				return filepath.SkipDir
-			}
-
-			if !d.IsDir() && d.Type().IsRegular() {
-				jobs <- path
-			}
-			bar.Add(1)
-			return nil
-		})
+
+		start := time.Now()
+		newWalker(options, func(path string) { jobs <- path }, func() { _ = bar.Add(1) }).walk(directory)
+		walked = time.Since(start)
 	}()
 
 	// waiting for workers and filepath.WalkDir() to finish
 	wg.Wait()
-	ex.Wait()
 
 	// let know goroutine collecting results from workers that we are done
 	close(results)
 	// wait for it to finish collecting found files
 	rg.Wait()
 
-	return files, excludedPaths
+	return files, walked
 }
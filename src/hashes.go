@@ -0,0 +1,121 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// iterations of PBKDF2 and cost of bcrypt below which hashes are considered weak
+const (
+	minPBKDF2Iterations = 10000
+	minBcryptCost       = 10
+)
+
+// LM hash of an empty password, present in pwdump output when LM hashes are disabled
+const emptyLMHash = "aad3b435b51404eeaad3b435b51404ee"
+
+// PasswordHash describes a password hash found in a file
+type PasswordHash struct {
+	Account string
+	Type    string
+	Hash    string
+	Weak    bool
+}
+
+type hashType struct {
+	name  string
+	regex *regexp.Regexp
+	weak  bool
+}
+
+var hashTypes = []hashType{
+	{name: "md5crypt", regex: regexp.MustCompile(`\$1\$[./0-9A-Za-z]{0,8}\$[./0-9A-Za-z]{22}`), weak: true},
+	{name: "apr1 (Apache MD5)", regex: regexp.MustCompile(`\$apr1\$[./0-9A-Za-z]{0,8}\$[./0-9A-Za-z]{22}`), weak: true},
+	{name: "sha256crypt", regex: regexp.MustCompile(`\$5\$(?:rounds=[0-9]+\$)?[./0-9A-Za-z]{0,16}\$[./0-9A-Za-z]{43}`)},
+	{name: "sha512crypt", regex: regexp.MustCompile(`\$6\$(?:rounds=[0-9]+\$)?[./0-9A-Za-z]{0,16}\$[./0-9A-Za-z]{86}`)},
+	{name: "yescrypt", regex: regexp.MustCompile(`\$y\$[./0-9A-Za-z]+\$[./0-9A-Za-z]*\$[./0-9A-Za-z]{43}`)},
+	{name: "bcrypt", regex: regexp.MustCompile(`\$2[abxy]?\$[0-9]{2}\$[./0-9A-Za-z]{53}`)},
+	{name: "PBKDF2", regex: regexp.MustCompile(`\$pbkdf2(?:-sha1|-sha256|-sha512)?\$[0-9]+\$[./0-9A-Za-z+=]+\$[./0-9A-Za-z+=]+`)},
+	{name: "PBKDF2 (Django)", regex: regexp.MustCompile(`pbkdf2_sha(?:1|256)\$[0-9]+\$[^$\s:]+\$[0-9A-Za-z+/=]+`)},
+	{name: "SHA1 (htpasswd)", regex: regexp.MustCompile(`\{SHA\}[0-9A-Za-z+/]{27}=`), weak: true},
+}
+
+// pwdump format: account:RID:LM hash:NT hash:::
+var pwdumpRegex = regexp.MustCompile(`^([^:\s]+):[0-9]+:([0-9a-fA-F]{32}):([0-9a-fA-F]{32}):::`)
+
+// account name preceding a hash as in /etc/shadow or htpasswd files
+var accountRegex = regexp.MustCompile(`^([^:\s]+):!*$`)
+
+// identifyHash looks for a password hash in a line of text. Strings of a found hash are copies, since the line can
+// share memory with a buffer of a whole file in the buffered mode.
+func identifyHash(text string) *PasswordHash {
+	if strings.IndexByte(text, '$') < 0 && !strings.Contains(text, ":::") && !strings.Contains(text, "{SHA}") {
+		return nil
+	}
+
+	if match := pwdumpRegex.FindStringSubmatch(text); len(match) > 0 {
+		account := strings.Clone(match[1])
+		if strings.ToLower(match[2]) != emptyLMHash {
+			return &PasswordHash{Account: account, Type: "LM", Hash: match[2] + ":" + match[3], Weak: true}
+		}
+		return &PasswordHash{Account: account, Type: "NTLM", Hash: strings.Clone(match[3]), Weak: true}
+	}
+
+	for _, ht := range hashTypes {
+		loc := ht.regex.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+
+		hash := &PasswordHash{Type: ht.name, Hash: strings.Clone(text[loc[0]:loc[1]])}
+		hash.Weak = ht.weak || isWeakWorkFactor(ht.name, hash.Hash)
+		if match := accountRegex.FindStringSubmatch(text[:loc[0]]); len(match) > 0 {
+			hash.Account = strings.Clone(match[1])
+		}
+		return hash
+	}
+	return nil
+}
+
+// isWeakWorkFactor checks whether iterations or cost of an adaptive hash are too low
+func isWeakWorkFactor(name string, hash string) bool {
+	fields := strings.FieldsFunc(hash, func(r rune) bool { return r == '$' })
+
+	switch name {
+	case "bcrypt":
+		if len(fields) > 1 {
+			cost, err := strconv.Atoi(fields[1])
+			return err == nil && cost < minBcryptCost
+		}
+	case "PBKDF2", "PBKDF2 (Django)":
+		if len(fields) > 1 {
+			iterations, err := strconv.Atoi(fields[1])
+			return err == nil && iterations < minPBKDF2Iterations
+		}
+	}
+	return false
+}
+
+// hashPattern stands for the pattern of password hash findings, which have no allowlists but are suppressed by
+// global stopwords like matches of patterns
+var hashPattern = Pattern{Name: "Password hash"}
+
+// scanForHash returns a secret describing a password hash found in a line of text
+func (app *App) scanForHash(file string, text string, line int) (Secret, bool) {
+	hash := identifyHash(text)
+	if hash == nil || app.suppressed(&hashPattern, hash.Hash, text, file) {
+		return Secret{}, false
+	}
+
+	secret := Secret{SecretType: hashPattern.Name, SecretValue: hash.Hash, LineNumber: line, Severity: severityMedium, Hash: hash}
+	secret.SetAttribute("hash type", hash.Type)
+	secret.SetAttribute("weak algorithm", strconv.FormatBool(hash.Weak))
+	if len(hash.Account) > 0 {
+		secret.SetAttribute("account", hash.Account)
+	}
+	if hash.Weak {
+		secret.Severity = severityHigh
+	}
+	return secret, true
+}
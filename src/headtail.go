@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+)
+
+// scanHeadTail scans only the head and the tail of a file larger than the maximum size. The middle of the file is
+// not read, so line numbers in the tail are unknown and secrets found there are located by byte offsets.
+func (app *App) scanHeadTail(file string, f *os.File, reader io.Reader, size int64, foundSecrets map[int]Secret) {
+	length := int64(*app.headTail) << 20
+	if 2*length >= size {
+		app.scanLines(file, reader, 1, 0, foundSecrets)
+		return
+	}
+
+	app.scanLines(file, io.LimitReader(reader, length), 1, 0, foundSecrets)
+
+	// reader reads f without buffering, so seeking f moves reader as well
+	if _, err := f.Seek(size-length, io.SeekStart); err != nil {
+		log.Printf("[!!] Tail of %s cannot be scanned due to %s\n", file, err.Error())
+		return
+	}
+	app.scanLines(file, reader, 0, size-length, foundSecrets)
+	app.stats.addPartial()
+}
@@ -56,3 +56,25 @@ func printFileInfo(filePath string) string {
 	}
 	return ""
 }
+
+// fileKey identifies a file by its device and inode
+type fileKey struct {
+	device uint64
+	inode  uint64
+}
+
+// fileKeyOf returns a device and an inode of a file, false when they are not available
+func fileKeyOf(info os.FileInfo) (fileKey, bool) {
+	if sysInfo, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fileKey{device: sysInfo.Dev, inode: sysInfo.Ino}, true
+	}
+	return fileKey{}, false
+}
+
+// hardLinks returns the number of hard links of a file
+func hardLinks(info os.FileInfo) uint64 {
+	if sysInfo, ok := info.Sys().(*syscall.Stat_t); ok {
+		return sysInfo.Nlink
+	}
+	return 1
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// inline annotation suppressing findings in the same or the following line, optionally limited to
+// a comma separated list of pattern names e.g. secrethunter:ignore=Generic API Key
+var ignoreAnnotationRegex = regexp.MustCompile(`secrets?hunter:ignore(?:=([^\r\n]*))?`)
+
+// comment terminators that can follow an annotation
+var commentTerminators = []string{"*/", "-->", "#}", "%>", "?>"}
+
+// IgnoreAnnotation describes an inline annotation found in a scanned line
+type IgnoreAnnotation struct {
+	names []string // lower case pattern names, empty for all patterns
+}
+
+// parseIgnoreAnnotation returns an annotation found in a line of text, nil if there is none
+func parseIgnoreAnnotation(text string) *IgnoreAnnotation {
+	if !strings.Contains(text, "hunter:ignore") {
+		return nil
+	}
+
+	match := ignoreAnnotationRegex.FindStringSubmatch(text)
+	if len(match) == 0 {
+		return nil
+	}
+
+	annotation := &IgnoreAnnotation{}
+	names := match[1]
+	for _, terminator := range commentTerminators {
+		if idx := strings.Index(names, terminator); idx >= 0 {
+			names = names[:idx]
+		}
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); len(name) > 0 {
+			annotation.names = append(annotation.names, name)
+		}
+	}
+	return annotation
+}
+
+// ignores checks whether an annotation suppresses a secret
+func (annotation *IgnoreAnnotation) ignores(secret Secret) bool {
+	if annotation == nil {
+		return false
+	}
+
+	if len(annotation.names) == 0 {
+		return true
+	}
+
+	for _, name := range annotation.names {
+		if name == strings.ToLower(secret.SecretType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// gitleaksAllowlist describes an allowlist of a gitleaks rule or a global gitleaks allowlist
+type gitleaksAllowlist struct {
+	Description string   `toml:"description"`
+	Condition   string   `toml:"condition"`
+	RegexTarget string   `toml:"regexTarget"`
+	Regexes     []string `toml:"regexes"`
+	Paths       []string `toml:"paths"`
+	Stopwords   []string `toml:"stopwords"`
+	Commits     []string `toml:"commits"`
+}
+
+type gitleaksRule struct {
+	ID          string              `toml:"id"`
+	Description string              `toml:"description"`
+	Regex       string              `toml:"regex"`
+	SecretGroup int                 `toml:"secretGroup"`
+	Entropy     float64             `toml:"entropy"`
+	Keywords    []string            `toml:"keywords"`
+	Path        string              `toml:"path"`
+	Tags        []string            `toml:"tags"`
+	Allowlist   *gitleaksAllowlist  `toml:"allowlist"`
+	Allowlists  []gitleaksAllowlist `toml:"allowlists"`
+}
+
+type gitleaksConfig struct {
+	Title  string `toml:"title"`
+	Extend struct {
+		UseDefault bool   `toml:"useDefault"`
+		Path       string `toml:"path"`
+	} `toml:"extend"`
+	Rules      []gitleaksRule      `toml:"rules"`
+	Allowlist  *gitleaksAllowlist  `toml:"allowlist"`
+	Allowlists []gitleaksAllowlist `toml:"allowlists"`
+}
+
+// trufflehogDetector describes a trufflehog v3 custom detector
+type trufflehogDetector struct {
+	Name                  string            `yaml:"name"`
+	Keywords              []string          `yaml:"keywords"`
+	Regex                 map[string]string `yaml:"regex"`
+	Entropy               float64           `yaml:"entropy"`
+	ExcludeWords          []string          `yaml:"exclude_words"`
+	ExcludeRegexesMatch   []string          `yaml:"exclude_regexes_match"`
+	ExcludeRegexesCapture []string          `yaml:"exclude_regexes_capture"`
+	Verify                []yaml.Node       `yaml:"verify"`
+}
+
+// importWarnings collects features of imported rules that are not supported, they are reported once per file
+type importWarnings struct {
+	file     string
+	features map[string]int
+}
+
+func newImportWarnings(file string) *importWarnings {
+	return &importWarnings{file: file, features: map[string]int{}}
+}
+
+func (w *importWarnings) add(feature string) {
+	w.features[feature]++
+}
+
+func (w *importWarnings) print() {
+	var features []string
+	for feature := range w.features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	for _, feature := range features {
+		log.Printf("[!!] %s: %s (%d rules)\n", w.file, feature, w.features[feature])
+	}
+}
+
+// wordsRegex converts a list of words into a case insensitive regex matching any of them
+func wordsRegex(words []string) string {
+	var quoted []string
+	for _, word := range words {
+		quoted = append(quoted, regexp.QuoteMeta(word))
+	}
+	return "(?i)(?:" + strings.Join(quoted, "|") + ")"
+}
+
+// importable checks whether a regex of an imported rule can be compiled, rules that cannot are skipped and
+// rules requiring the backtracking engine are switched to it
+func importable(pattern *Pattern, warnings *importWarnings) bool {
+	if _, err := regexp.Compile(pattern.Regex); err != nil {
+		if pcreOnly(pattern.Regex) {
+			pattern.Engine = enginePCRE
+			warnings.add("regexes not supported by Go are matched with engine: " + enginePCRE)
+			return true
+		}
+		log.Printf("[!!] %s: rule %q is skipped, its regex is not supported: %s\n", warnings.file, pattern.Name, err.Error())
+		return false
+	}
+	return true
+}
+
+// applyGitleaksAllowlist maps a gitleaks allowlist to allowlists of a pattern
+func applyGitleaksAllowlist(pattern *Pattern, allowlist gitleaksAllowlist, warnings *importWarnings) {
+	pattern.Allow = append(pattern.Allow, allowlist.Regexes...)
+	pattern.AllowPaths = append(pattern.AllowPaths, allowlist.Paths...)
+
+	if len(allowlist.Stopwords) > 0 {
+		pattern.Allow = append(pattern.Allow, wordsRegex(allowlist.Stopwords))
+		warnings.add("allowlist stopwords are matched against whole lines, not only secrets")
+	}
+	if len(allowlist.Commits) > 0 {
+		warnings.add("allowlist commits are not supported and are ignored")
+	}
+	if len(allowlist.RegexTarget) > 0 && len(allowlist.Regexes) > 0 {
+		warnings.add("allowlist regexTarget is not supported, allowlist regexes are matched against secrets and whole lines")
+	}
+	if strings.EqualFold(allowlist.Condition, "AND") && len(allowlist.Regexes)+len(allowlist.Stopwords) > 0 && len(allowlist.Paths) > 0 {
+		warnings.add("allowlist condition AND is not supported, allowlist entries are applied with OR")
+	}
+}
+
+// importGitleaks converts rules of a gitleaks TOML configuration into patterns
+func importGitleaks(file string, content []byte) ([]Pattern, error) {
+	var config gitleaksConfig
+
+	meta, err := toml.Decode(string(content), &config)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := newImportWarnings(file)
+	for _, key := range meta.Undecoded() {
+		log.Printf("[!!] %s: setting %s is not supported and is ignored\n", file, key.String())
+	}
+	if config.Extend.UseDefault || len(config.Extend.Path) > 0 {
+		log.Printf("[!!] %s: extending other gitleaks configurations is not supported, use -extend to add built-in patterns\n", file)
+	}
+
+	globalAllowlists := config.Allowlists
+	if config.Allowlist != nil {
+		globalAllowlists = append(globalAllowlists, *config.Allowlist)
+	}
+
+	var patterns []Pattern
+	for _, rule := range config.Rules {
+		if len(rule.Regex) == 0 {
+			log.Printf("[!!] %s: rule %q is skipped, rules matching only file paths are not supported\n", file, rule.ID)
+			continue
+		}
+		pattern := Pattern{
+			ID:          rule.ID,
+			Name:        rule.Description,
+			Regex:       rule.Regex,
+			Confidence:  severityMedium,
+			SecretGroup: rule.SecretGroup,
+			Entropy:     rule.Entropy,
+			Path:        rule.Path,
+			Keywords:    rule.Keywords,
+		}
+		if len(pattern.Name) == 0 {
+			pattern.Name = rule.ID
+		}
+		// secretGroup 0 of gitleaks selects the first non-empty group, the whole match only when there is none
+		if pattern.SecretGroup == 0 {
+			pattern.SecretGroup = secretGroupFirst
+		}
+		if !importable(&pattern, warnings) {
+			continue
+		}
+
+		allowlists := rule.Allowlists
+		if rule.Allowlist != nil {
+			allowlists = append(allowlists, *rule.Allowlist)
+		}
+		for _, allowlist := range append(allowlists, globalAllowlists...) {
+			applyGitleaksAllowlist(&pattern, allowlist, warnings)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	warnings.print()
+	return patterns, nil
+}
+
+// importTrufflehogRegexes converts a trufflehog v2 JSON file mapping names to regexes into patterns
+func importTrufflehogRegexes(file string, content []byte) ([]Pattern, error) {
+	var regexes map[string]string
+	if err := json.Unmarshal(content, &regexes); err != nil {
+		return nil, fmt.Errorf("trufflehog regexes file cannot be parsed: %s", err.Error())
+	}
+
+	var names []string
+	for name := range regexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	warnings := newImportWarnings(file)
+	var patterns []Pattern
+	for _, name := range names {
+		pattern := Pattern{Name: name, Regex: regexes[name], Confidence: severityMedium}
+		if importable(&pattern, warnings) {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	warnings.print()
+	return patterns, nil
+}
+
+// importTrufflehogDetectors converts trufflehog v3 custom detectors into patterns, one pattern per regex
+func importTrufflehogDetectors(file string, content []byte) ([]Pattern, error) {
+	var config struct {
+		Detectors []trufflehogDetector `yaml:"detectors"`
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+
+	warnings := newImportWarnings(file)
+	var patterns []Pattern
+	for _, detector := range config.Detectors {
+		if len(detector.Verify) > 0 {
+			warnings.add("verify webhooks are not supported and are ignored, use verifiers of patterns")
+		}
+		if len(detector.Regex) > 1 {
+			warnings.add("regexes of detectors with several regexes are converted to independent patterns")
+		}
+
+		var keys []string
+		for key := range detector.Regex {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			name := detector.Name
+			if len(keys) > 1 {
+				name = fmt.Sprintf("%s (%s)", detector.Name, key)
+			}
+			pattern := Pattern{Name: name, Regex: detector.Regex[key], Confidence: severityMedium, Entropy: detector.Entropy, Keywords: detector.Keywords}
+			if !importable(&pattern, warnings) {
+				continue
+			}
+			pattern.Allow = append(pattern.Allow, detector.ExcludeRegexesMatch...)
+			pattern.Allow = append(pattern.Allow, detector.ExcludeRegexesCapture...)
+			if len(detector.ExcludeWords) > 0 {
+				pattern.Allow = append(pattern.Allow, wordsRegex(detector.ExcludeWords))
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	warnings.print()
+	return patterns, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// interval of measurements of system I/O wait
+const iowaitMeasureInterval = time.Second
+
+// ReadLimiter caps read throughput of all workers
+type ReadLimiter struct {
+	mutex sync.Mutex
+	rate  float64   // bytes per second
+	next  time.Time // time when the next read is allowed
+}
+
+func newReadLimiter(bytesPerSecond int64) *ReadLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &ReadLimiter{rate: float64(bytesPerSecond)}
+}
+
+// wait accounts read bytes and blocks until reads done so far fit in the rate
+func (l *ReadLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	l.mutex.Unlock()
+
+	time.Sleep(delay)
+}
+
+// throttledReader is a reader limited by a ReadLimiter
+type throttledReader struct {
+	reader  io.Reader
+	limiter *ReadLimiter
+}
+
+func (r throttledReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.limiter.wait(n)
+	return n, err
+}
+
+// IOWaitMonitor pauses scanning while system I/O wait exceeds a threshold
+type IOWaitMonitor struct {
+	threshold float64
+	mutex     sync.RWMutex
+	stop      chan struct{}
+	done      sync.WaitGroup
+	stats     *Statistics
+}
+
+// cpuTimes returns I/O wait and total CPU time from the first line of /proc/stat
+func cpuTimes() (iowait uint64, total uint64, ok bool) {
+	fd, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer func() { _ = fd.Close() }()
+
+	scanner := bufio.NewScanner(fd)
+	if !scanner.Scan() {
+		return 0, 0, false
+	}
+
+	// cpu user nice system idle iowait irq softirq steal ...
+	fields := strings.Fields(scanner.Text())
+	if len(fields) < 6 || fields[0] != "cpu" {
+		return 0, 0, false
+	}
+	for idx, field := range fields[1:] {
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		// guest times are already included in user and nice times
+		if idx < 8 {
+			total += value
+		}
+		if idx == 4 {
+			iowait = value
+		}
+	}
+	return iowait, total, true
+}
+
+func newIOWaitMonitor(threshold int, stats *Statistics) *IOWaitMonitor {
+	if threshold <= 0 {
+		return nil
+	}
+	return &IOWaitMonitor{threshold: float64(threshold), stop: make(chan struct{}), stats: stats}
+}
+
+// Start measures I/O wait periodically, workers are blocked in Wait while it exceeds the threshold
+func (m *IOWaitMonitor) Start() {
+	if m == nil {
+		return
+	}
+
+	m.done.Add(1)
+	go func() {
+		defer m.done.Done()
+
+		var locked bool
+		ticker := time.NewTicker(iowaitMeasureInterval)
+		defer ticker.Stop()
+
+		iowait1, total1, _ := cpuTimes()
+		for {
+			select {
+			case <-m.stop:
+				if locked {
+					m.mutex.Unlock()
+				}
+				return
+			case <-ticker.C:
+			}
+
+			iowait2, total2, ok := cpuTimes()
+			if !ok || total2 <= total1 {
+				continue
+			}
+			load := float64(iowait2-iowait1) / float64(total2-total1) * 100
+			iowait1, total1 = iowait2, total2
+
+			switch {
+			case load > m.threshold && !locked:
+				m.mutex.Lock()
+				locked = true
+				m.stats.addIOWaitPause()
+			case load <= m.threshold && locked:
+				m.mutex.Unlock()
+				locked = false
+			}
+		}
+	}()
+}
+
+// Stop stops measurements and releases blocked workers
+func (m *IOWaitMonitor) Stop() {
+	if m == nil {
+		return
+	}
+	close(m.stop)
+	m.done.Wait()
+}
+
+// Wait blocks while I/O wait exceeds the threshold
+func (m *IOWaitMonitor) Wait() {
+	if m == nil {
+		return
+	}
+	m.mutex.RLock()
+	m.mutex.RUnlock()
+}
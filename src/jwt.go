@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JWTInfo describes claims of a JSON Web Token
+type JWTInfo struct {
+	Algorithm string
+	Issuer    string
+	Subject   string
+	Audience  string
+	ExpiresAt time.Time // zero when a token does not expire
+	Expired   bool
+	WeakAlg   bool // alg=none or HS256 signed with a shared secret
+}
+
+// claimString converts a string or a list of strings claim to a string
+func claimString(claims map[string]interface{}, name string) string {
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			values = append(values, fmt.Sprint(v))
+		}
+		return strings.Join(values, ",")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// decodeJWT decodes header and payload of the first JWT found in a text, signature is not verified
+func decodeJWT(text string) (*JWTInfo, error) {
+	token := jwtRegex.FindString(text)
+	if len(token) == 0 {
+		return nil, fmt.Errorf("no JWT found")
+	}
+
+	parts := strings.Split(token, ".")
+	header, err := decodeJWTPart(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodeJWTPart(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	info := &JWTInfo{
+		Algorithm: claimString(header, "alg"),
+		Issuer:    claimString(payload, "iss"),
+		Subject:   claimString(payload, "sub"),
+		Audience:  claimString(payload, "aud"),
+	}
+
+	alg := strings.ToLower(info.Algorithm)
+	info.WeakAlg = alg == "none" || alg == "hs256"
+
+	if exp, ok := payload["exp"].(float64); ok {
+		info.ExpiresAt = time.Unix(int64(exp), 0).UTC()
+		info.Expired = time.Now().After(info.ExpiresAt)
+	}
+	return info, nil
+}
+
+// enrichJWT adds claims of a JWT to a secret, expired tokens are downgraded
+func enrichJWT(_ string, secret *Secret) {
+	if !strings.Contains(secret.SecretValue, "eyJ") {
+		return
+	}
+
+	info, err := decodeJWT(secret.SecretValue)
+	if err != nil {
+		return
+	}
+
+	secret.JWT = info
+	secret.SetAttribute("jwt algorithm", info.Algorithm)
+	for name, value := range map[string]string{"jwt issuer": info.Issuer, "jwt subject": info.Subject, "jwt audience": info.Audience} {
+		if len(value) > 0 {
+			secret.SetAttribute(name, value)
+		}
+	}
+
+	if info.WeakAlg {
+		secret.SetAttribute("jwt weak algorithm", "true")
+	}
+
+	if !info.ExpiresAt.IsZero() {
+		secret.SetAttribute("jwt expires", info.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if info.Expired {
+		secret.SetAttribute("jwt expired", "true")
+		secret.Severity = severityLow
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maximum number of lines of a PEM block that are read when analysing a private key
+const maxKeyBlockLines = 1000
+
+var privateKeyHeader = regexp.MustCompile(`-----BEGIN ((?:[A-Z0-9]+ )*)PRIVATE KEY-----`)
+
+// KeyInfo describes a private key found in a file
+type KeyInfo struct {
+	Format      string // PEM block type e.g. RSA, OPENSSH, PKCS#8
+	Algorithm   string
+	Bits        int
+	Encrypted   bool
+	Fingerprint string // SHA256 fingerprint of the public key in OpenSSH format
+	PublicKey   ssh.PublicKey
+}
+
+// readPEMBlock reads a PEM block from a file starting at the given line
+func readPEMBlock(file string, line int) ([]byte, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fd.Close() }()
+
+	var block strings.Builder
+	var started bool
+
+	scanner := bufio.NewScanner(fd)
+	for cnt := 1; scanner.Scan(); cnt++ {
+		if cnt < line {
+			continue
+		}
+		if cnt > line+maxKeyBlockLines {
+			break
+		}
+
+		text := strings.TrimSpace(scanner.Text())
+		if !started {
+			// a header can be preceded by other content in the same line
+			if idx := strings.Index(text, "-----BEGIN"); idx >= 0 {
+				text = text[idx:]
+				started = true
+			} else {
+				continue
+			}
+		}
+
+		block.WriteString(text)
+		block.WriteString("\n")
+
+		if strings.HasPrefix(text, "-----END") {
+			return []byte(block.String()), nil
+		}
+	}
+	return nil, errors.New("end of PEM block not found")
+}
+
+// analysePrivateKey parses a private key that starts in the given line of a file
+func analysePrivateKey(file string, line int) (*KeyInfo, error) {
+	data, err := readPEMBlock(file, line)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("malformed PEM block")
+	}
+
+	info := &KeyInfo{Format: strings.TrimSuffix(block.Type, " PRIVATE KEY")}
+
+	var key interface{}
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		// encrypted PKCS#8 does not reveal the key algorithm nor the public key
+		info.Format = "PKCS#8"
+		info.Encrypted = true
+		return info, nil
+	case "PRIVATE KEY":
+		info.Format = "PKCS#8"
+		if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	default:
+		// legacy PEM encryption announced with Proc-Type and DEK-Info headers
+		if x509.IsEncryptedPEMBlock(block) {
+			info.Encrypted = true
+			info.Algorithm = info.Format
+			return info, nil
+		}
+
+		if key, err = ssh.ParseRawPrivateKey(data); err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				info.Encrypted = true
+				if missing.PublicKey != nil {
+					info.setPublicKey(missing.PublicKey)
+				}
+				return info, nil
+			}
+			return nil, err
+		}
+	}
+
+	if signer, err := ssh.NewSignerFromKey(key); err == nil {
+		info.setPublicKey(signer.PublicKey())
+	} else {
+		info.Algorithm, info.Bits = describeKey(key)
+	}
+	return info, nil
+}
+
+func (info *KeyInfo) setPublicKey(pub ssh.PublicKey) {
+	info.PublicKey = pub
+	info.Fingerprint = ssh.FingerprintSHA256(pub)
+	info.Algorithm = pub.Type()
+
+	if cpk, ok := pub.(ssh.CryptoPublicKey); ok {
+		_, info.Bits = describeKey(cpk.CryptoPublicKey())
+	}
+}
+
+// describeKey returns algorithm and size of a public or private key
+func describeKey(key interface{}) (string, int) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return "RSA", k.N.BitLen()
+	case *rsa.PublicKey:
+		return "RSA", k.N.BitLen()
+	case *ecdsa.PrivateKey:
+		return "ECDSA", k.Curve.Params().BitSize
+	case *ecdsa.PublicKey:
+		return "ECDSA", k.Curve.Params().BitSize
+	case ed25519.PrivateKey, *ed25519.PrivateKey, ed25519.PublicKey:
+		return "ED25519", 256
+	case *dsa.PrivateKey:
+		return "DSA", k.P.BitLen()
+	case *dsa.PublicKey:
+		return "DSA", k.P.BitLen()
+	default:
+		return fmt.Sprintf("%T", key), 0
+	}
+}
+
+// enrichPrivateKey adds key metadata to a secret matching a private key header. Encrypted keys are downgraded.
+func enrichPrivateKey(file string, secret *Secret) {
+	// keys in tails of oversized files cannot be read by line numbers
+	if len(secret.Encoding) > 0 || secret.LineNumber == 0 || !privateKeyHeader.MatchString(secret.SecretValue) {
+		return
+	}
+
+	info, err := analysePrivateKey(file, secret.LineNumber)
+	if err != nil {
+		secret.SetAttribute("key", "cannot be parsed: "+err.Error())
+		return
+	}
+
+	secret.Key = info
+	secret.SetAttribute("key format", info.Format)
+	secret.SetAttribute("key encrypted", strconv.FormatBool(info.Encrypted))
+	if len(info.Algorithm) > 0 {
+		secret.SetAttribute("key algorithm", info.Algorithm)
+	}
+	if info.Bits > 0 {
+		secret.SetAttribute("key size", strconv.Itoa(info.Bits))
+	}
+	if len(info.Fingerprint) > 0 {
+		secret.SetAttribute("key fingerprint", info.Fingerprint)
+	}
+
+	if info.Encrypted {
+		secret.Severity = severityLow
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"github.com/dlclark/regexp2"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// regex engines of patterns
+const (
+	engineRE2  = "re2"  // Go regexp package, linear time matching
+	enginePCRE = "pcre" // backtracking engine supporting lookarounds and backreferences
+)
+
+// maximum time the backtracking engine can spend looking for a match in a line, or for the next match in a block
+// in the buffered mode
+const pcreMatchTimeout = 100 * time.Millisecond
+
+// Matcher is implemented by compiled regular expressions of all engines
+type Matcher interface {
+	FindStringSubmatch(text string) []string
+	FindAllStringSubmatchIndex(text string, n int) [][]int
+	NumSubexp() int
+	String() string
+}
+
+// pcreMatcher wraps a regexp2 regular expression
+type pcreMatcher struct {
+	re      *regexp2.Regexp
+	timeout sync.Once
+}
+
+func (m *pcreMatcher) FindStringSubmatch(text string) []string {
+	match, err := m.re.FindStringMatch(text)
+	if err != nil {
+		m.timeout.Do(func() {
+			log.Printf("[!!] Matching of regex %q timed out after %s, lines where it happens are not matched\n", m.re.String(), pcreMatchTimeout)
+		})
+		return nil
+	}
+	if match == nil {
+		return nil
+	}
+
+	groups := match.Groups()
+	result := make([]string, len(groups))
+	for idx, group := range groups {
+		result[idx] = group.String()
+	}
+	return result
+}
+
+// FindAllStringSubmatchIndex returns byte offsets of successive matches and their groups, -1 for groups that
+// did not participate in a match
+func (m *pcreMatcher) FindAllStringSubmatchIndex(text string, n int) [][]int {
+	result, _ := m.findAll(text, n)
+	return result
+}
+
+// findAll returns byte offsets of successive matches like FindAllStringSubmatchIndex, and an error when matching
+// timed out, matches following the timeout are then missing
+func (m *pcreMatcher) findAll(text string, n int) ([][]int, error) {
+	var result [][]int
+
+	// regexp2 reports offsets in runes, only reported offsets are converted to byte offsets
+	offsets := runeOffsets{text: text}
+
+	match, err := m.re.FindStringMatch(text)
+	for ; err == nil && match != nil && (n < 0 || len(result) < n); match, err = m.re.FindNextMatch(match) {
+		groups := match.Groups()
+		loc := make([]int, 2*len(groups))
+		for idx, group := range groups {
+			loc[2*idx], loc[2*idx+1] = -1, -1
+			if len(group.Captures) > 0 {
+				loc[2*idx] = offsets.byteOffset(group.Index)
+				loc[2*idx+1] = offsets.byteOffset(group.Index + group.Length)
+			}
+		}
+		result = append(result, loc)
+	}
+
+	if err != nil {
+		m.timeout.Do(func() {
+			log.Printf("[!!] Matching of regex %q timed out after %s, matches following the timeout are not reported and files where it happens are listed among skipped files\n", m.re.String(), pcreMatchTimeout)
+		})
+	}
+	return result, err
+}
+
+// runeOffsets converts rune offsets to byte offsets of a text by walking it from the last converted offset.
+// Matches are found in increasing order, so a text is walked about once however many offsets are converted.
+type runeOffsets struct {
+	text  string
+	runes int // rune offset of the cursor
+	bytes int // byte offset of the cursor
+}
+
+func (o *runeOffsets) byteOffset(runes int) int {
+	for o.runes < runes && o.bytes < len(o.text) {
+		_, size := utf8.DecodeRuneInString(o.text[o.bytes:])
+		o.bytes += size
+		o.runes++
+	}
+	// groups in lookbehinds can precede the end of the previous match
+	for o.runes > runes && o.bytes > 0 {
+		_, size := utf8.DecodeLastRuneInString(o.text[:o.bytes])
+		o.bytes -= size
+		o.runes--
+	}
+	return o.bytes
+}
+
+// findAllIndex returns byte offsets of all matches of a regex and their groups, and an error when matching with
+// the backtracking engine timed out
+func findAllIndex(m Matcher, text string) ([][]int, error) {
+	if pcre, ok := m.(*pcreMatcher); ok {
+		return pcre.findAll(text, -1)
+	}
+	return m.FindAllStringSubmatchIndex(text, -1), nil
+}
+
+func (m *pcreMatcher) NumSubexp() int {
+	return len(m.re.GetGroupNumbers()) - 1
+}
+
+func (m *pcreMatcher) String() string {
+	return m.re.String()
+}
+
+func compilePCRE(regex string) (*pcreMatcher, error) {
+	re, err := regexp2.Compile(regex, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+	re.MatchTimeout = pcreMatchTimeout
+	return &pcreMatcher{re: re}, nil
+}
+
+// compileMatcher compiles a regular expression with the given engine
+func compileMatcher(regex string, engine string) (Matcher, error) {
+	switch engine {
+	case "", engineRE2:
+		return regexp.Compile(regex)
+	case enginePCRE:
+		return compilePCRE(regex)
+	default:
+		return nil, fmt.Errorf("unknown regex engine %q, expected %s or %s", engine, engineRE2, enginePCRE)
+	}
+}
+
+// pcreOnly checks whether a regex not supported by Go regexp package can be compiled by the backtracking engine
+func pcreOnly(regex string) bool {
+	if _, err := regexp.Compile(regex); err == nil {
+		return false
+	}
+	_, err := compilePCRE(regex)
+	return err == nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPCREByteOffsets(t *testing.T) {
+	tests := []struct {
+		regex string
+		text  string
+	}{
+		{regex: `key=([a-z]+)`, text: "key=abc key=def"},
+		{regex: `key=([a-zé]+)`, text: "żółw key=éa\nkey=b ü key=éé"},
+		{regex: `([a-z])?(ß+)`, text: "ßß aß 日本ß"},
+		{regex: `x`, text: "日本語"},
+		{regex: `(a)|(b)`, text: "€a€b€"},
+	}
+
+	for _, test := range tests {
+		pcre, err := compilePCRE(test.regex)
+		if err != nil {
+			t.Fatalf("regex %q cannot be compiled: %s", test.regex, err.Error())
+		}
+
+		got, err := pcre.findAll(test.text, -1)
+		if err != nil {
+			t.Errorf("findAll(%q) on %q failed: %s", test.regex, test.text, err.Error())
+		}
+		if want := regexp.MustCompile(test.regex).FindAllStringSubmatchIndex(test.text, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("findAll(%q) on %q = %v, want %v", test.regex, test.text, got, want)
+		}
+	}
+}
+
+func TestPCREByteOffsetsOfLookbehind(t *testing.T) {
+	pcre, err := compilePCRE(`(?<=(ü+))x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the group of the second match starts before the end of the first match
+	got, err := pcre.findAll("üüxüx", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{4, 5, 0, 4}, {7, 8, 5, 7}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findAll() = %v, want %v", got, want)
+	}
+}
+
+func TestPCRETimeout(t *testing.T) {
+	pcre, err := compilePCRE(`(x+x+)+y`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := findAllIndex(pcre, strings.Repeat("x", 64)); err == nil {
+		t.Errorf("catastrophic backtracking did not time out")
+	}
+	if _, err := findAllIndex(regexp.MustCompile(`(x+x+)+y`), strings.Repeat("x", 64)); err != nil {
+		t.Errorf("Go regexp reported an error: %s", err.Error())
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync/atomic"
+	"time"
+)
+
+// share of a cgroup memory limit used as the default memory ceiling
+const defaultMemoryShare = 0.8
+
+// discovery of files slows down when the heap exceeds this share of the memory ceiling
+const memoryPressure = 0.9
+
+// the heap size is checked once per this number of walked paths
+const memoryCheckInterval = 256
+
+// discovery waits for memory to be released at most this number of times per check
+const maxMemoryWaits = 50
+
+const memoryWait = 100 * time.Millisecond
+
+// MemoryGuard slows discovery of files down when the heap approaches the memory ceiling
+type MemoryGuard struct {
+	ceiling uint64
+	walked  int64
+	stats   *Statistics
+}
+
+// newMemoryGuard sets the memory ceiling as a soft limit of the runtime, nil is returned when there is no ceiling
+func newMemoryGuard(ceiling uint64, stats *Statistics) *MemoryGuard {
+	if ceiling == 0 {
+		return nil
+	}
+
+	debug.SetMemoryLimit(int64(ceiling))
+	return &MemoryGuard{ceiling: ceiling, stats: stats}
+}
+
+func heapSize() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// wait is called for every walked path, it blocks while the heap is close to the ceiling
+func (guard *MemoryGuard) wait() {
+	if guard == nil || atomic.AddInt64(&guard.walked, 1)%memoryCheckInterval != 0 {
+		return
+	}
+
+	threshold := uint64(float64(guard.ceiling) * memoryPressure)
+	if heapSize() < threshold {
+		return
+	}
+
+	guard.stats.addMemoryPause()
+	runtime.GC()
+	for cnt := 0; cnt < maxMemoryWaits && heapSize() >= threshold; cnt++ {
+		time.Sleep(memoryWait)
+		runtime.GC()
+	}
+}
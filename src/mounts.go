@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const mountInfoFile = "/proc/self/mountinfo"
+
+// pseudoFilesystems are types of file systems with kernel interfaces and devices rather than files worth scanning
+var pseudoFilesystems = map[string]bool{
+	"autofs":      true,
+	"binfmt_misc": true,
+	"bpf":         true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"configfs":    true,
+	"debugfs":     true,
+	"devpts":      true,
+	"devtmpfs":    true,
+	"efivarfs":    true,
+	"fuse":        true,
+	"fusectl":     true,
+	"hugetlbfs":   true,
+	"mqueue":      true,
+	"nsfs":        true,
+	"proc":        true,
+	"pstore":      true,
+	"rpc_pipefs":  true,
+	"securityfs":  true,
+	"selinuxfs":   true,
+	"sysfs":       true,
+	"tracefs":     true,
+}
+
+// Mount is a mounted file system described in /proc/self/mountinfo
+type Mount struct {
+	Device     uint64 // device number as reported by stat
+	MountPoint string
+	Type       string
+}
+
+// isPseudo checks whether a file system is a pseudo file system, FUSE file systems have types fuse.<subtype>
+func (m Mount) isPseudo() bool {
+	return pseudoFilesystems[m.Type] || strings.HasPrefix(m.Type, "fuse.")
+}
+
+// mkdev encodes major and minor numbers of a device the way the kernel reports them in stat
+func mkdev(major uint64, minor uint64) uint64 {
+	return (major&0xfffff000)<<32 | (major&0xfff)<<8 | (minor&0xffffff00)<<12 | minor&0xff
+}
+
+// unescapeMountPath decodes octal escapes of spaces, tabs, newlines and backslashes in paths of mountinfo
+func unescapeMountPath(path string) string {
+	var b strings.Builder
+	for idx := 0; idx < len(path); idx++ {
+		if path[idx] == '\\' && idx+3 < len(path) {
+			if code, err := strconv.ParseUint(path[idx+1:idx+4], 8, 8); err == nil {
+				b.WriteByte(byte(code))
+				idx += 3
+				continue
+			}
+		}
+		b.WriteByte(path[idx])
+	}
+	return b.String()
+}
+
+// parseMountInfo parses a line of mountinfo e.g.
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfo(line string) (Mount, error) {
+	fields := strings.Fields(line)
+
+	separator := -1
+	for idx := 6; idx < len(fields); idx++ {
+		if fields[idx] == "-" {
+			separator = idx
+			break
+		}
+	}
+	if separator < 0 || separator+1 >= len(fields) {
+		return Mount{}, fmt.Errorf("malformed line %q", line)
+	}
+
+	var major, minor uint64
+	if _, err := fmt.Sscanf(fields[2], "%d:%d", &major, &minor); err != nil {
+		return Mount{}, fmt.Errorf("malformed device %q", fields[2])
+	}
+
+	return Mount{Device: mkdev(major, minor), MountPoint: unescapeMountPath(fields[4]), Type: fields[separator+1]}, nil
+}
+
+// readMounts returns file systems mounted in the mount namespace of the process
+func readMounts() ([]Mount, error) {
+	fd, err := os.Open(mountInfoFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fd.Close() }()
+
+	var mounts []Mount
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		mount, err := parseMountInfo(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, scanner.Err()
+}
+
+// pseudoDevices returns devices of mounted pseudo file systems mapped to their mount points
+func pseudoDevices(mounts []Mount) map[uint64]string {
+	devices := map[uint64]string{}
+	for _, mount := range mounts {
+		if mount.isPseudo() {
+			devices[mount.Device] = mount.MountPoint
+		}
+	}
+	return devices
+}
@@ -0,0 +1,120 @@
+package main
+
+import "testing"
+
+func TestMkdev(t *testing.T) {
+	tests := []struct {
+		major uint64
+		minor uint64
+		want  uint64
+	}{
+		{major: 0, minor: 22, want: 0x16},
+		{major: 8, minor: 1, want: 0x801},
+		{major: 254, minor: 16, want: 0xfe10},
+		{major: 259, minor: 0, want: 0x10300},
+		{major: 0, minor: 256, want: 0x100000},
+		{major: 0, minor: 1048575, want: 0xfff000ff},
+		{major: 4095, minor: 255, want: 0xfffff},
+		{major: 4096, minor: 0, want: 0x100000000000},
+		{major: 0xfffff, minor: 0xfffff, want: 0xff000ffffffff},
+		{major: 0xffffffff, minor: 0xffffffff, want: 0xffffffffffffffff},
+	}
+
+	for _, test := range tests {
+		if got := mkdev(test.major, test.minor); got != test.want {
+			t.Errorf("mkdev(%d, %d) = %#x, want %#x", test.major, test.minor, got, test.want)
+		}
+	}
+}
+
+func TestUnescapeMountPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/mnt/data", want: "/mnt/data"},
+		{path: `/mnt/my\040disk`, want: "/mnt/my disk"},
+		{path: `/mnt/a\011b\012c`, want: "/mnt/a\tb\nc"},
+		{path: `/mnt/back\134slash`, want: `/mnt/back\slash`},
+		{path: `/mnt/end\040`, want: "/mnt/end "},
+		{path: `/mnt/short\04`, want: `/mnt/short\04`},
+		{path: `/mnt/not\999octal`, want: `/mnt/not\999octal`},
+	}
+
+	for _, test := range tests {
+		if got := unescapeMountPath(test.path); got != test.want {
+			t.Errorf("unescapeMountPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestParseMountInfo(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   Mount
+		pseudo bool
+	}{
+		{
+			line: "36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue",
+			want: Mount{Device: mkdev(98, 0), MountPoint: "/mnt2", Type: "ext3"},
+		},
+		{
+			// no optional fields
+			line:   "22 28 0:21 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw",
+			want:   Mount{Device: mkdev(0, 21), MountPoint: "/proc", Type: "proc"},
+			pseudo: true,
+		},
+		{
+			// several optional fields
+			line:   "30 23 0:26 / /sys/fs/cgroup/memory rw,nosuid shared:12 master:3 propagate_from:2 - cgroup cgroup rw,memory",
+			want:   Mount{Device: mkdev(0, 26), MountPoint: "/sys/fs/cgroup/memory", Type: "cgroup"},
+			pseudo: true,
+		},
+		{
+			line: `412 29 0:52 / /media/usb\040stick rw,relatime shared:220 - vfat /dev/sdb1 rw,fmask=0022`,
+			want: Mount{Device: mkdev(0, 52), MountPoint: "/media/usb stick", Type: "vfat"},
+		},
+		{
+			line:   "101 29 0:48 / /home/user/remote rw,nosuid,nodev,relatime shared:60 - fuse.sshfs user@host:/ rw,user_id=1000",
+			want:   Mount{Device: mkdev(0, 48), MountPoint: "/home/user/remote", Type: "fuse.sshfs"},
+			pseudo: true,
+		},
+		{
+			line: "25 1 259:1048575 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw",
+			want: Mount{Device: mkdev(259, 1048575), MountPoint: "/", Type: "ext4"},
+		},
+		{
+			line: "26 25 4096:7 / /data rw - xfs /dev/dm-7 rw",
+			want: Mount{Device: mkdev(4096, 7), MountPoint: "/data", Type: "xfs"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseMountInfo(test.line)
+		if err != nil {
+			t.Errorf("parseMountInfo(%q) failed: %s", test.line, err.Error())
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseMountInfo(%q) = %+v, want %+v", test.line, got, test.want)
+		}
+		if got.isPseudo() != test.pseudo {
+			t.Errorf("parseMountInfo(%q).isPseudo() = %t, want %t", test.line, got.isPseudo(), test.pseudo)
+		}
+	}
+}
+
+func TestParseMountInfoMalformed(t *testing.T) {
+	lines := []string{
+		"",
+		"36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 ext3 /dev/root rw",
+		"36 35 98:0 /mnt1 /mnt2 rw,noatime -",
+		"36 35 disk /mnt1 /mnt2 rw,noatime - ext3 /dev/root rw",
+	}
+
+	for _, line := range lines {
+		if mount, err := parseMountInfo(line); err == nil {
+			t.Errorf("parseMountInfo(%q) = %+v, want an error", line, mount)
+		}
+	}
+}
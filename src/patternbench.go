@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// benchResult summarises a scan of a corpus by a matching backend
+type benchResult struct {
+	elapsed time.Duration
+	matches []string // pattern ID and match for every line, empty for lines without a match
+}
+
+// benchScan scans every line of a corpus the way files are scanned
+func benchScan(app *App, corpus []string) benchResult {
+	result := benchResult{matches: make([]string, len(corpus))}
+
+	start := time.Now()
+	for idx, line := range corpus {
+		if pattern, match := app.scanWithRegex("", line); pattern != nil {
+			result.matches[idx] = pattern.ID + " " + match
+		}
+	}
+	result.elapsed = time.Since(start)
+	return result
+}
+
+// differences counts lines with different results of two scans
+func differences(a benchResult, b benchResult) int {
+	var cnt int
+	for idx := range a.matches {
+		if a.matches[idx] != b.matches[idx] {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// patternsBench measures matching speed of all matching backends and checks that their results are identical
+func patternsBench(args []string) int {
+	flags := flag.NewFlagSet("patterns bench", flag.ExitOnError)
+	var patternsFiles stringList
+	flags.Var(&patternsFiles, "p", "`file or directory` with secret patterns, can be repeated, default patterns are measured\nwhen not provided")
+	extend := flags.Bool("extend", false, "`extends default patterns` with patterns provided with -p")
+	corpusPath := flags.String("corpus", "", "`file or directory` scanned by the benchmark, a synthetic corpus is generated when not provided")
+	verbose := flags.Bool("verbose", false, "`prints keywords` of every pattern")
+	_ = flags.Parse(args)
+
+	patterns, err := LoadPatterns(patternsFiles, *extend, nil)
+	if err != nil {
+		log.Printf("[!!] Secret patterns cannot be loaded due to %s\n", err.Error())
+		return 1
+	}
+
+	var corpus []string
+	if len(*corpusPath) > 0 {
+		if corpus, err = readCorpus(*corpusPath); err != nil {
+			log.Printf("[!!] Corpus %s cannot be read due to %s\n", *corpusPath, err.Error())
+			return 1
+		}
+	} else {
+		corpus = syntheticCorpus(syntheticCorpusLines)
+	}
+
+	// positive examples make sure that the corpus contains secrets
+	for _, pattern := range patterns.Get() {
+		corpus = append(corpus, pattern.Positive...)
+	}
+
+	forced := true
+	app := &App{patterns: patterns, forceFlg: &forced, stopwords: defaultStopwords}
+	prefilter := newPrefilter(patterns.Get())
+
+	fmt.Printf("[*] Benchmarking %d patterns on %d lines, keyword prefiltering covers %d patterns\n", patterns.Num(), len(corpus), prefilter.Covered())
+	if *verbose {
+		for _, pattern := range patterns.Get() {
+			fmt.Printf("\t%s: %s\n", pattern.ID, strings.Join(pattern.keywords(), ", "))
+		}
+	}
+
+	baseline := benchScan(app, corpus)
+	fmt.Printf("[*] Regex loop: %s\n", baseline.elapsed)
+
+	combined := newRegexSet(patterns.Get(), func(regex string) string { return regex })
+	backends := []struct {
+		name      string
+		prefilter *Prefilter
+		combined  *RegexSet
+	}{
+		{name: "Keyword prefiltering", prefilter: prefilter},
+		{name: fmt.Sprintf("Combined matching (%d patterns)", combined.Covered()), combined: combined},
+		{name: "Keyword prefiltering and combined matching", prefilter: prefilter, combined: combined},
+	}
+
+	var failed bool
+	for _, backend := range backends {
+		app.prefilter, app.combined = backend.prefilter, backend.combined
+		result := benchScan(app, corpus)
+		fmt.Printf("[*] %s: %s, %.1f times faster\n", backend.name, result.elapsed, float64(baseline.elapsed)/float64(result.elapsed))
+
+		if cnt := differences(baseline, result); cnt > 0 {
+			fmt.Printf("[!!] %s changed results of %d lines\n", backend.name, cnt)
+			failed = true
+		}
+	}
+	if failed {
+		return 1
+	}
+
+	fmt.Printf("[+] Results are identical\n")
+	return 0
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+)
+
+func patternsUsage() {
+	_, _ = fmt.Fprintf(os.Stderr, `
+Usage: secretshunter patterns COMMAND [OPTIONS]
+
+COMMANDS:
+  test
+	compiles secret patterns and checks that every pattern detects its
+	positive examples and does not detect its negative examples
+  lint
+	detects duplicated names and regular expressions, patterns subsumed
+	by other patterns, unanchored leading .* and patterns whose cost
+	measured on a corpus is excessive
+  bench
+	measures matching speed of secret patterns on a corpus with and
+	without keyword prefiltering and combined matching and checks that
+	results are identical
+  convert [-o file] FILE...
+	converts secret patterns, gitleaks TOML rules or trufflehog rules
+	(v2 JSON regexes or v3 YAML custom detectors) into a secrethunter
+	YAML file printed to standard output or written to the -o file
+`)
+}
+
+// patternsCommand runs a patterns subcommand and returns an exit code
+func patternsCommand(args []string) int {
+	if len(args) == 0 {
+		patternsUsage()
+		return 2
+	}
+
+	switch args[0] {
+	case "test":
+		return patternsTest(args[1:])
+	case "lint":
+		return patternsLint(args[1:])
+	case "bench":
+		return patternsBench(args[1:])
+	case "convert":
+		return patternsConvert(args[1:])
+	default:
+		log.Printf("[!!] Unknown patterns command %q\n", args[0])
+		patternsUsage()
+		return 2
+	}
+}
+
+// patternsTest runs positive and negative examples of every pattern
+func patternsTest(args []string) int {
+	flags := flag.NewFlagSet("patterns test", flag.ExitOnError)
+	var patternsFiles stringList
+	flags.Var(&patternsFiles, "p", "`file or directory` with secret patterns, can be repeated, default patterns are tested\nwhen not provided")
+	extend := flags.Bool("extend", false, "`extends default patterns` with patterns provided with -p")
+	_ = flags.Parse(args)
+
+	patterns, err := LoadPatterns(patternsFiles, *extend, nil)
+	if err != nil {
+		log.Printf("[!!] Secret patterns cannot be loaded due to %s\n", err.Error())
+		return 1
+	}
+
+	var examples, failed, failedPatterns, untested int
+	for _, pattern := range patterns.Get() {
+		var failures []string
+
+		if len(pattern.Positive) == 0 && len(pattern.Negative) == 0 {
+			untested++
+			continue
+		}
+
+		for _, example := range pattern.Positive {
+			examples++
+			if !pattern.matches(example) {
+				failures = append(failures, fmt.Sprintf("positive example is not detected: %q", example))
+			}
+		}
+
+		for _, example := range pattern.Negative {
+			examples++
+			if pattern.matches(example) {
+				failures = append(failures, fmt.Sprintf("negative example is detected: %q", example))
+			}
+		}
+
+		if len(failures) > 0 {
+			failedPatterns++
+			failed += len(failures)
+			fmt.Printf("[!!] Pattern %q with regex %q failed:\n", pattern.Name, pattern.Regex)
+			for _, failure := range failures {
+				fmt.Printf("\t%s\n", failure)
+			}
+		}
+	}
+
+	fmt.Printf("[*] Tested %d patterns with %d examples\n", patterns.Num()-untested, examples)
+	if untested > 0 {
+		fmt.Printf("[-] %d patterns have no examples\n", untested)
+	}
+
+	if failed > 0 {
+		fmt.Printf("[!!] %d examples of %d patterns failed\n", failed, failedPatterns)
+		return 1
+	}
+
+	fmt.Printf("[+] All examples passed\n")
+	return 0
+}
+
+// patternsConvert writes patterns loaded from files of any supported format as a secrethunter YAML file
+func patternsConvert(args []string) int {
+	flags := flag.NewFlagSet("patterns convert", flag.ExitOnError)
+	outFile := flags.String("o", "", "`output file` for converted patterns, standard output when not provided")
+	_ = flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		log.Printf("[!!] No files to convert provided\n")
+		patternsUsage()
+		return 2
+	}
+
+	patterns, err := LoadPatterns(flags.Args(), false, nil)
+	if err != nil {
+		log.Printf("[!!] Secret patterns cannot be loaded due to %s\n", err.Error())
+		return 1
+	}
+
+	data := PatternsFile{}
+	for _, pattern := range patterns.Get() {
+		data.Patterns = append(data.Patterns, struct {
+			Pattern Pattern `yaml:"pattern"`
+		}{Pattern: pattern})
+	}
+
+	out := os.Stdout
+	if len(*outFile) > 0 {
+		if out, err = os.Create(*outFile); err != nil {
+			log.Printf("[!!] Output file %s cannot be created due to %s\n", *outFile, err.Error())
+			return 1
+		}
+		defer func() { _ = out.Close() }()
+	}
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&data); err != nil {
+		log.Printf("[!!] Converted patterns cannot be written due to %s\n", err.Error())
+		return 1
+	}
+	_ = encoder.Close()
+
+	for _, line := range patterns.Summary() {
+		log.Printf("[*] Converted %s\n", line)
+	}
+	return 0
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp/syntax"
+	"strings"
+	"time"
+)
+
+// a pattern is too expensive when its cost exceeds the average cost of all patterns multiplied by this factor
+const defaultMaxCostFactor = 5.0
+
+// costs below this number of nanoseconds per line are never reported
+const minReportedCost = 200
+
+// size of a synthetic corpus generated when no corpus is provided
+const syntheticCorpusLines = 20000
+
+// LintIssue describes a problem found in secret patterns
+type LintIssue struct {
+	Kind    string
+	Message string
+}
+
+// readCorpus reads lines of a file or of all regular files in a directory
+func readCorpus(path string) ([]string, error) {
+	var lines []string
+
+	err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		fd, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = fd.Close() }()
+
+		scanner := bufio.NewScanner(fd)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		return nil
+	})
+	return lines, err
+}
+
+// syntheticCorpus generates deterministic lines resembling configuration files, logs and minified data
+func syntheticCorpus(lines int) []string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789+/=_-.:@'\" "
+
+	rnd := rand.New(rand.NewSource(1))
+	word := func(n int) string {
+		var b strings.Builder
+		for cnt := 0; cnt < n; cnt++ {
+			b.WriteByte(alphabet[rnd.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+
+	templates := []func() string{
+		func() string { return fmt.Sprintf("%s = %s", word(8), word(24)) },
+		func() string { return fmt.Sprintf(`  "%s": "%s",`, word(10), word(40)) },
+		func() string {
+			return fmt.Sprintf("2023-08-01T12:00:00Z INFO [%s] request from %s finished in %dms", word(6), word(15), rnd.Intn(1000))
+		},
+		func() string { return fmt.Sprintf("# %s %s %s", word(12), word(20), word(12)) },
+		func() string { return fmt.Sprintf("url: https://%s/%s?%s", word(12), word(20), word(30)) },
+		func() string { return word(2000) },
+	}
+
+	corpus := make([]string, lines)
+	for idx := range corpus {
+		corpus[idx] = templates[rnd.Intn(len(templates))]()
+	}
+	return corpus
+}
+
+// concatFactors returns top level sub-expressions of a concatenation
+func concatFactors(re *syntax.Regexp) []*syntax.Regexp {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	if re.Op == syntax.OpConcat {
+		return re.Sub
+	}
+	return []*syntax.Regexp{re}
+}
+
+// literal returns the string of a case sensitive literal, false for other expressions
+func literal(re *syntax.Regexp) (string, bool) {
+	if re.Op == syntax.OpLiteral && re.Flags&syntax.FoldCase == 0 {
+		return string(re.Rune), true
+	}
+	return "", false
+}
+
+// factorMatches compares a factor of b with a factor of a, a literal at an edge of b can be a part of a literal of a
+func factorMatches(fa *syntax.Regexp, fb *syntax.Regexp, first bool, last bool) bool {
+	if fa.Equal(fb) {
+		return true
+	}
+
+	la, okA := literal(fa)
+	lb, okB := literal(fb)
+	if !okA || !okB {
+		return false
+	}
+
+	switch {
+	case first && last:
+		return strings.Contains(la, lb)
+	case first:
+		return strings.HasSuffix(la, lb)
+	case last:
+		return strings.HasPrefix(la, lb)
+	}
+	return false
+}
+
+// subsumes checks whether every match of regex a contains a match of regex b, which is the case when
+// a contiguous sequence of top level factors of a matches all factors of b
+func subsumes(a *syntax.Regexp, b *syntax.Regexp) bool {
+	fa, fb := concatFactors(a), concatFactors(b)
+
+	for start := 0; start+len(fb) <= len(fa); start++ {
+		equal := true
+		for idx := range fb {
+			if !factorMatches(fa[start+idx], fb[idx], idx == 0, idx == len(fb)-1) {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLeadingWildcard checks whether a regex starts with unanchored .* or .+
+func hasLeadingWildcard(re *syntax.Regexp) bool {
+	first := concatFactors(re)[0]
+	if first.Op != syntax.OpStar && first.Op != syntax.OpPlus {
+		return false
+	}
+	sub := first.Sub[0]
+	return sub.Op == syntax.OpAnyCharNotNL || sub.Op == syntax.OpAnyChar
+}
+
+// measureCosts returns average time in nanoseconds needed by every pattern to scan a line of a corpus
+func measureCosts(patterns []Pattern, corpus []string) []float64 {
+	costs := make([]float64, len(patterns))
+	for idx, pattern := range patterns {
+		start := time.Now()
+		for _, line := range corpus {
+			pattern.CompiledRegex.FindStringSubmatch(line)
+		}
+		costs[idx] = float64(time.Since(start).Nanoseconds()) / float64(len(corpus))
+	}
+	return costs
+}
+
+func average(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	if len(values) == 0 {
+		return 0
+	}
+	return sum / float64(len(values))
+}
+
+// lintPatterns finds duplicated, subsumed and expensive patterns
+func lintPatterns(patterns []Pattern, corpus []string, maxCostFactor float64) []LintIssue {
+	var issues []LintIssue
+
+	names := map[string][]int{}
+	regexes := map[string][]int{}
+	parsed := make([]*syntax.Regexp, len(patterns))
+
+	for idx, pattern := range patterns {
+		names[pattern.Name] = append(names[pattern.Name], idx)
+		regexes[pattern.Regex] = append(regexes[pattern.Regex], idx)
+
+		// structure of regexes of the backtracking engine is not analysed
+		if pattern.Engine == enginePCRE {
+			continue
+		}
+
+		re, err := syntax.Parse(pattern.Regex, syntax.Perl)
+		if err != nil {
+			issues = append(issues, LintIssue{Kind: "invalid regex", Message: fmt.Sprintf("pattern %q: %s", pattern.Name, err.Error())})
+			continue
+		}
+		parsed[idx] = re.Simplify()
+	}
+
+	describe := func(indexes []int) string {
+		var list []string
+		for _, idx := range indexes {
+			list = append(list, fmt.Sprintf("#%d %q", idx+1, patterns[idx].Name))
+		}
+		return strings.Join(list, ", ")
+	}
+
+	for idx, pattern := range patterns {
+		if indexes := names[pattern.Name]; len(indexes) > 1 && indexes[0] == idx {
+			issues = append(issues, LintIssue{Kind: "duplicate name", Message: fmt.Sprintf("%q is used by patterns %s", pattern.Name, describe(indexes))})
+		}
+		if indexes := regexes[pattern.Regex]; len(indexes) > 1 && indexes[0] == idx {
+			issues = append(issues, LintIssue{Kind: "duplicate regex", Message: fmt.Sprintf("%q is used by patterns %s", pattern.Regex, describe(indexes))})
+		}
+	}
+
+	for a := range patterns {
+		if parsed[a] == nil {
+			continue
+		}
+
+		if hasLeadingWildcard(parsed[a]) {
+			issues = append(issues, LintIssue{Kind: "leading wildcard", Message: fmt.Sprintf("pattern #%d %q starts with unanchored .* or .+ in %q", a+1, patterns[a].Name, patterns[a].Regex)})
+		}
+
+		for b := range patterns {
+			if a == b || parsed[b] == nil || patterns[a].Regex == patterns[b].Regex {
+				continue
+			}
+
+			if subsumes(parsed[a], parsed[b]) {
+				message := fmt.Sprintf("every match of pattern #%d %q contains a match of pattern #%d %q", a+1, patterns[a].Name, b+1, patterns[b].Name)
+				if b < a {
+					message += ", which is evaluated first, so the former never reports anything"
+				}
+				issues = append(issues, LintIssue{Kind: "subsumed", Message: message})
+				continue
+			}
+
+			// rules detecting all positive examples of another rule overlap with it
+			if len(patterns[a].Positive) > 0 {
+				overlap := true
+				for _, example := range patterns[a].Positive {
+					if !patterns[b].matches(example) {
+						overlap = false
+						break
+					}
+				}
+				if overlap {
+					issues = append(issues, LintIssue{Kind: "overlapping", Message: fmt.Sprintf("all positive examples of pattern #%d %q are detected by pattern #%d %q", a+1, patterns[a].Name, b+1, patterns[b].Name)})
+				}
+			}
+		}
+	}
+
+	if len(corpus) > 0 {
+		costs := measureCosts(patterns, corpus)
+		avg := average(costs)
+		for idx, cost := range costs {
+			if cost > avg*maxCostFactor && cost > minReportedCost {
+				issues = append(issues, LintIssue{Kind: "expensive", Message: fmt.Sprintf("pattern #%d %q needs %.0fns per line, %.1f times more than the average", idx+1, patterns[idx].Name, cost, cost/avg)})
+			}
+		}
+	}
+	return issues
+}
+
+// patternsLint runs lintPatterns on default patterns or patterns from a file
+func patternsLint(args []string) int {
+	flags := flag.NewFlagSet("patterns lint", flag.ExitOnError)
+	var patternsFiles stringList
+	flags.Var(&patternsFiles, "p", "`file or directory` with secret patterns, can be repeated, default patterns are linted\nwhen not provided")
+	extend := flags.Bool("extend", false, "`extends default patterns` with patterns provided with -p")
+	corpusPath := flags.String("corpus", "", "`file or directory` used to measure cost of patterns, a synthetic corpus is generated when not provided")
+	maxCostFactor := flags.Float64("maxcost", defaultMaxCostFactor, "`factor` of the average cost above which a pattern is reported as expensive")
+	_ = flags.Parse(args)
+
+	patterns, err := LoadPatterns(patternsFiles, *extend, nil)
+	if err != nil {
+		log.Printf("[!!] Secret patterns cannot be loaded due to %s\n", err.Error())
+		return 1
+	}
+
+	var corpus []string
+	if len(*corpusPath) > 0 {
+		if corpus, err = readCorpus(*corpusPath); err != nil {
+			log.Printf("[!!] Corpus %s cannot be read due to %s\n", *corpusPath, err.Error())
+			return 1
+		}
+	} else {
+		corpus = syntheticCorpus(syntheticCorpusLines)
+	}
+
+	fmt.Printf("[*] Linting %d patterns, cost is measured on %d lines\n", patterns.Num(), len(corpus))
+	issues := lintPatterns(patterns.Get(), corpus, *maxCostFactor)
+
+	for _, issue := range issues {
+		fmt.Printf("[!!] %s: %s\n", issue.Kind, issue.Message)
+	}
+
+	if len(issues) > 0 {
+		fmt.Printf("[!!] Found %d issues\n", len(issues))
+		return 1
+	}
+
+	fmt.Printf("[+] No issues found\n")
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestLintPatterns(t *testing.T) {
+	patterns := &Patterns{}
+	err := patterns.read(`
+- name: Key
+  regex: "key_[0-9]{4}"
+  positive: ["key_1234", "id key_0000"]
+- name: Identifier
+  regex: "[a-z]+_[0-9]+"
+- name: Token
+  regex: "tok_[0-9]{4}"
+- name: Token
+  regex: "tok_[0-9]{4}"
+- name: Anything
+  regex: ".*secret=[a-z]+"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the identifier pattern detects all examples of the key pattern without containing its every match
+	kinds := map[string]int{}
+	for _, issue := range lintPatterns(patterns.Get(), []string{"key_1234", "nothing"}, 1000) {
+		kinds[issue.Kind]++
+	}
+	for _, kind := range []string{"duplicate name", "duplicate regex", "overlapping", "leading wildcard"} {
+		if kinds[kind] != 1 {
+			t.Errorf("found %d %s issues, want 1", kinds[kind], kind)
+		}
+	}
+}
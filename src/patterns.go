@@ -1,17 +1,44 @@
 package main
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"log"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 )
 
+// secret_group selecting the first non-empty capture group of a match, like secretGroup 0 of gitleaks rules
+const secretGroupFirst = -1
+
 type Pattern struct {
-	Name          string         `yaml:"name"`
-	Regex         string         `yaml:"regex"`
-	Confidence    string         `yaml:"confidence"`
-	CompiledRegex *regexp.Regexp `yaml:"-"`
+	ID            string    `yaml:"id,omitempty"` // identifies a pattern when overriding or disabling it
+	Disabled      bool      `yaml:"disabled,omitempty"`
+	Name          string    `yaml:"name"`
+	Regex         string    `yaml:"regex"`
+	Confidence    string    `yaml:"confidence"`
+	Engine        string    `yaml:"engine,omitempty"`       // re2 (default) or pcre
+	SecretGroup   int       `yaml:"secret_group,omitempty"` // capture group holding the secret, 0 for the whole match, -1 for the first non-empty group
+	Entropy       float64   `yaml:"entropy,omitempty"`      // minimum Shannon entropy of the secret
+	Path          string    `yaml:"path,omitempty"`         // regex of paths of files the pattern applies to
+	Keywords      []string  `yaml:"keywords,omitempty"`     // literals of which a line has to contain one to be matched
+	Validator     string    `yaml:"validator,omitempty"`
+	OnInvalid     string    `yaml:"on_invalid,omitempty"` // discard (default) or downgrade matches failing validation
+	Verifier      *Verifier `yaml:"verifier,omitempty"`
+	Allow         []string  `yaml:"allow,omitempty"`       // regexes matched against a secret and its line marking benign matches
+	AllowPaths    []string  `yaml:"allow_paths,omitempty"` // regexes of paths of files where matches are benign
+	Positive      []string  `yaml:"positive,omitempty"`    // examples that the pattern has to detect
+	Negative      []string  `yaml:"negative,omitempty"`    // examples that the pattern must not detect
+	CompiledRegex Matcher   `yaml:"-"`
+
+	bufferRegex      Matcher // regex with ^ and $ matching at line boundaries used to match whole files
+	pathRegex        *regexp.Regexp
+	allowRegexes     []*regexp.Regexp
+	allowPathRegexes []*regexp.Regexp
+	generatedID      bool // the ID was derived from the name, such patterns never override other patterns
 }
 
 type PatternsFile struct {
@@ -23,6 +50,15 @@ type PatternsFile struct {
 type Patterns struct {
 	file     string
 	patterns []Pattern
+	sources  []PatternSource
+}
+
+// PatternSource describes where loaded patterns came from
+type PatternSource struct {
+	Name       string
+	Loaded     int // patterns added by the source
+	Overridden int // patterns of earlier sources replaced by the source
+	Disabled   int // patterns of earlier sources disabled by the source
 }
 
 func NewPatterns(fileWithPatterns string) (*Patterns, error) {
@@ -46,35 +82,50 @@ func DefaultPatterns() (*Patterns, error) {
 }
 
 func (p *Patterns) load() error {
-	fd, err := os.Open(p.file)
+	content, err := os.ReadFile(p.file)
 	if err != nil {
 		return err
 	}
-	defer fd.Close()
 
-	data := PatternsFile{}
-	err = yaml.NewDecoder(fd).Decode(&data)
+	switch strings.ToLower(filepath.Ext(p.file)) {
+	case ".toml":
+		p.patterns, err = importGitleaks(p.file, content)
+	case ".json":
+		p.patterns, err = importTrufflehogRegexes(p.file, content)
+	default:
+		p.patterns, err = readPatternsFile(p.file, content)
+	}
 	if err != nil {
 		return err
 	}
 
-	p.patterns = []Pattern{}
-	for _, dataElement := range data.Patterns {
-		dataElement.Pattern.CompiledRegex, err = regexp.Compile(dataElement.Pattern.Regex)
-		if err != nil {
-			log.Fatalf("Compilation of regex %q failed with error: %s\nAborting!!!\n", dataElement.Pattern.Regex, err.Error())
-		}
-		p.patterns = append(p.patterns, dataElement.Pattern)
+	return p.compile()
+}
+
+// readPatternsFile reads patterns from a secrethunter YAML file or trufflehog custom detectors
+func readPatternsFile(file string, content []byte) ([]Pattern, error) {
+	data := PatternsFile{}
+	err := yaml.Unmarshal(content, &data)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if len(data.Patterns) == 0 {
+		return importTrufflehogDetectors(file, content)
+	}
+
+	patterns := []Pattern{}
+	for _, dataElement := range data.Patterns {
+		patterns = append(patterns, dataElement.Pattern)
+	}
+	return patterns, nil
 }
 
 func (p *Patterns) read(yamlPatterns string) error {
 	var data []Pattern
 	var err error
 
-	err = yaml.Unmarshal([]byte(defaultPatterns), &data)
+	err = yaml.Unmarshal([]byte(yamlPatterns), &data)
 	if err != nil {
 		return err
 	}
@@ -82,16 +133,270 @@ func (p *Patterns) read(yamlPatterns string) error {
 	p.patterns = data
 	p.file = ""
 
-	for idx, pattern := range p.patterns {
-		p.patterns[idx].CompiledRegex, err = regexp.Compile(pattern.Regex)
-		if err != nil {
-			log.Fatalf("Compilation of regex %q failed with error: %s\nAborting!!!\n", pattern.Regex, err.Error())
+	return p.compile()
+}
+
+// compile compiles regular expressions of patterns and assigns IDs to patterns without one
+func (p *Patterns) compile() error {
+	var err error
+
+	ids := map[string]bool{}
+	compiled := make([]Pattern, 0, len(p.patterns))
+	for _, pattern := range p.patterns {
+		if pattern.Engine != "" && pattern.Engine != engineRE2 && pattern.Engine != enginePCRE {
+			return fmt.Errorf("pattern %q uses unknown regex engine %q, expected %s or %s", pattern.Name, pattern.Engine, engineRE2, enginePCRE)
+		}
+		if pattern.CompiledRegex, err = compileMatcher(pattern.Regex, pattern.Engine); err != nil {
+			if pattern.Engine != enginePCRE && pcreOnly(pattern.Regex) {
+				log.Printf("[!!] Pattern %q is skipped, its regex %q requires engine: %s\n", pattern.Name, pattern.Regex, enginePCRE)
+			} else {
+				log.Printf("[!!] Pattern %q is skipped, compilation of its regex %q failed with error: %s\n", pattern.Name, pattern.Regex, err.Error())
+			}
+			continue
+		}
+		if pattern.bufferRegex, err = compileMatcher("(?m:"+pattern.Regex+")", pattern.Engine); err != nil {
+			log.Printf("[!!] Pattern %q is skipped, its regex %q cannot be used in multi-line mode: %s\n", pattern.Name, pattern.Regex, err.Error())
+			continue
 		}
+		if pattern.SecretGroup < secretGroupFirst || pattern.SecretGroup > pattern.CompiledRegex.NumSubexp() {
+			return fmt.Errorf("pattern %q has secret_group %d but its regex has %d groups", pattern.Name, pattern.SecretGroup, pattern.CompiledRegex.NumSubexp())
+		}
+		if len(pattern.Path) > 0 {
+			if pattern.pathRegex, err = regexp.Compile(pattern.Path); err != nil {
+				return fmt.Errorf("pattern %q has invalid path regex %q: %s", pattern.Name, pattern.Path, err.Error())
+			}
+		}
+		if pattern.Verifier != nil {
+			if err = pattern.Verifier.compile(); err != nil {
+				return fmt.Errorf("pattern %q has %s", pattern.Name, err.Error())
+			}
+		}
+		if err = pattern.checkValidator(); err != nil {
+			return err
+		}
+		if err = pattern.compileAllowlists(); err != nil {
+			return err
+		}
+
+		if len(pattern.ID) == 0 {
+			pattern.ID = uniqueID(slug(pattern.Name), ids)
+			pattern.generatedID = true
+		} else if ids[pattern.ID] {
+			return fmt.Errorf("pattern ID %q is not unique", pattern.ID)
+		}
+		ids[pattern.ID] = true
+
+		compiled = append(compiled, pattern)
 	}
+	p.patterns = compiled
 
 	return nil
 }
 
+var nonAlphanumericRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug converts a pattern name to an ID e.g. "SSH (EC) private key" to "ssh-ec-private-key"
+func slug(name string) string {
+	return strings.Trim(nonAlphanumericRegex.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func uniqueID(id string, ids map[string]bool) string {
+	unique := id
+	for cnt := 2; ids[unique]; cnt++ {
+		unique = fmt.Sprintf("%s-%d", id, cnt)
+	}
+	return unique
+}
+
+// patternFiles returns a file or pattern files of a directory
+func patternFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	for _, ext := range []string{"*.yaml", "*.yml", "*.toml", "*.json"} {
+		found, err := filepath.Glob(filepath.Join(path, ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no pattern files found in directory %s", path)
+	}
+	return files, nil
+}
+
+// LoadPatterns loads patterns from files and directories. Built-in patterns are used when no paths are provided
+// or extend is set. Patterns with IDs of earlier loaded patterns override them, or remove them when disabled.
+// Patterns with IDs listed in disabled are removed at the end.
+func LoadPatterns(paths []string, extend bool, disabled []string) (*Patterns, error) {
+	p := &Patterns{}
+
+	if len(paths) == 0 || extend {
+		defaults, err := DefaultPatterns()
+		if err != nil {
+			return nil, err
+		}
+		p.patterns = defaults.patterns
+		p.sources = append(p.sources, PatternSource{Name: "built-in patterns", Loaded: defaults.Num()})
+	}
+
+	for _, path := range paths {
+		files, err := patternFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			loaded, err := NewPatterns(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err.Error())
+			}
+			p.sources = append(p.sources, p.merge(file, loaded.patterns))
+		}
+	}
+
+	if len(disabled) > 0 {
+		source := PatternSource{Name: "disabled with -disable option"}
+		for _, id := range disabled {
+			if idx := p.index(strings.TrimSpace(id)); idx >= 0 {
+				p.patterns = append(p.patterns[:idx], p.patterns[idx+1:]...)
+				source.Disabled++
+			} else {
+				log.Printf("[!!] Pattern with ID %q cannot be disabled, it does not exist\n", id)
+			}
+		}
+		p.sources = append(p.sources, source)
+	}
+
+	if len(p.patterns) == 0 {
+		return nil, fmt.Errorf("no enabled secret patterns")
+	}
+	return p, nil
+}
+
+// index returns the position of a pattern with the given ID, -1 when there is none
+func (p *Patterns) index(id string) int {
+	for idx, pattern := range p.patterns {
+		if pattern.ID == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// merge adds patterns of a source, overriding or disabling already loaded patterns with the same explicit IDs.
+// IDs derived from names are made unique instead, so that a pattern sharing a name does not replace another one.
+func (p *Patterns) merge(name string, patterns []Pattern) PatternSource {
+	source := PatternSource{Name: name}
+
+	for _, pattern := range patterns {
+		if pattern.generatedID && p.index(pattern.ID) >= 0 {
+			ids := map[string]bool{}
+			for _, loaded := range p.patterns {
+				ids[loaded.ID] = true
+			}
+			pattern.ID = uniqueID(slug(pattern.Name), ids)
+		}
+
+		idx := p.index(pattern.ID)
+		switch {
+		case idx >= 0 && pattern.Disabled:
+			p.patterns = append(p.patterns[:idx], p.patterns[idx+1:]...)
+			source.Disabled++
+		case idx >= 0:
+			p.patterns[idx] = pattern
+			source.Overridden++
+		case pattern.Disabled:
+			log.Printf("[!!] Pattern with ID %q from %s cannot be disabled, it does not exist\n", pattern.ID, name)
+		default:
+			p.patterns = append(p.patterns, pattern)
+			source.Loaded++
+		}
+	}
+	return source
+}
+
+// Summary describes how many patterns came from each source
+func (p *Patterns) Summary() []string {
+	var summary []string
+	for _, source := range p.sources {
+		var counts []string
+		if source.Loaded > 0 || (source.Overridden == 0 && source.Disabled == 0) {
+			counts = append(counts, fmt.Sprintf("%d loaded", source.Loaded))
+		}
+		if source.Overridden > 0 {
+			counts = append(counts, fmt.Sprintf("%d overridden", source.Overridden))
+		}
+		if source.Disabled > 0 {
+			counts = append(counts, fmt.Sprintf("%d disabled", source.Disabled))
+		}
+		summary = append(summary, fmt.Sprintf("%s: %s", source.Name, strings.Join(counts, ", ")))
+	}
+	return summary
+}
+
+// matches checks whether the pattern detects a secret in a text applying its validator and allowlists
+func (p *Pattern) matches(text string) bool {
+	for _, match := range p.findAll(text) {
+		if p.discards() && !p.validate(match) {
+			continue
+		}
+		if !p.allowed(match, text, "") {
+			return true
+		}
+	}
+	return false
+}
+
+// findAll returns secrets matched by a pattern in a text from the leftmost one, empty secrets and secrets whose
+// entropy is too low are left out
+func (p *Pattern) findAll(text string) []string {
+	var secrets []string
+	for _, loc := range p.CompiledRegex.FindAllStringSubmatchIndex(text, -1) {
+		group := p.group(len(loc)/2-1, func(group int) bool { return loc[2*group] >= loc[2*group+1] })
+		start, end := loc[2*group], loc[2*group+1]
+		if start >= end {
+			continue
+		}
+
+		secret := text[start:end]
+		if p.Entropy > 0 && shannonEntropy(secret) < p.Entropy {
+			continue
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+// group returns the capture group of a match holding the secret, empty reports whether a group matched nothing.
+// With secret_group -1 it is the first non-empty group, or the whole match when all groups are empty.
+func (p *Pattern) group(groups int, empty func(group int) bool) int {
+	if p.SecretGroup != secretGroupFirst {
+		return p.SecretGroup
+	}
+	for group := 1; group <= groups; group++ {
+		if !empty(group) {
+			return group
+		}
+	}
+	return 0
+}
+
+// appliesTo checks whether a pattern is used for a file, patterns without a path regex apply to all files
+func (p *Pattern) appliesTo(file string) bool {
+	return p.pathRegex == nil || len(file) == 0 || p.pathRegex.MatchString(file)
+}
+
 func (p *Patterns) Get() []Pattern {
 	return p.patterns
 }
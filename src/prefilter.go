@@ -0,0 +1,284 @@
+package main
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+)
+
+// minimum length of literals derived from regexes, shorter literals are too common to filter anything
+const minKeywordLength = 3
+
+// maximum number of alternative literals derived from a regex
+const maxKeywordVariants = 16
+
+// Prefilter selects patterns worth evaluating for a line based on keywords of patterns found in it
+type Prefilter struct {
+	automaton       *ahoCorasick
+	keywordPatterns [][]int // indexes of patterns of every keyword
+	always          []int   // indexes of patterns without keywords, evaluated on every line
+	patterns        int
+}
+
+func newPrefilter(patterns []Pattern) *Prefilter {
+	f := &Prefilter{patterns: len(patterns)}
+
+	var keywords []string
+	ids := map[string]int{}
+	for idx := range patterns {
+		patternKeywords := patterns[idx].keywords()
+		if len(patternKeywords) == 0 {
+			f.always = append(f.always, idx)
+			continue
+		}
+
+		for _, keyword := range patternKeywords {
+			id, ok := ids[keyword]
+			if !ok {
+				id = len(keywords)
+				ids[keyword] = id
+				keywords = append(keywords, keyword)
+				f.keywordPatterns = append(f.keywordPatterns, nil)
+			}
+			f.keywordPatterns[id] = append(f.keywordPatterns[id], idx)
+		}
+	}
+
+	f.automaton = newAhoCorasick(keywords)
+	return f
+}
+
+// candidates returns a flag for every pattern telling whether it has to be evaluated for a line
+func (f *Prefilter) candidates(text string) []bool {
+	selected := make([]bool, f.patterns)
+	for _, idx := range f.always {
+		selected[idx] = true
+	}
+
+	f.automaton.scan(text, func(keyword int) {
+		for _, idx := range f.keywordPatterns[keyword] {
+			selected[idx] = true
+		}
+	})
+	return selected
+}
+
+// Covered returns the number of patterns having keywords
+func (f *Prefilter) Covered() int {
+	return f.patterns - len(f.always)
+}
+
+// keywords returns lower case keywords of a pattern, declared ones or literals derived from its regex.
+// Every match of the pattern contains at least one of them. Patterns without keywords return nil.
+func (p *Pattern) keywords() []string {
+	if len(p.Keywords) > 0 {
+		var keywords []string
+		for _, keyword := range p.Keywords {
+			keywords = append(keywords, strings.ToLower(keyword))
+		}
+		return keywords
+	}
+
+	if p.Engine == enginePCRE {
+		return nil
+	}
+
+	re, err := syntax.Parse(p.Regex, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	if keywords, ok := requiredLiterals(re.Simplify()); ok {
+		return keywords
+	}
+	return nil
+}
+
+// exactLiterals returns all lower case strings matched by a regex when there are only a few of them
+func exactLiterals(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		literal := strings.ToLower(string(re.Rune))
+		return []string{literal}, isASCII(literal)
+	case syntax.OpCharClass:
+		return classLiterals(re.Rune)
+	case syntax.OpCapture:
+		return exactLiterals(re.Sub[0])
+	case syntax.OpQuest:
+		literals, ok := exactLiterals(re.Sub[0])
+		return union([]string{""}, literals), ok && len(literals) < maxKeywordVariants
+	case syntax.OpConcat:
+		literals := []string{""}
+		for _, sub := range re.Sub {
+			subLiterals, ok := exactLiterals(sub)
+			if !ok {
+				return nil, false
+			}
+			if literals = product(literals, subLiterals); literals == nil {
+				return nil, false
+			}
+		}
+		return literals, true
+	case syntax.OpAlternate:
+		var literals []string
+		for _, sub := range re.Sub {
+			subLiterals, ok := exactLiterals(sub)
+			if !ok {
+				return nil, false
+			}
+			literals = union(literals, subLiterals)
+		}
+		return literals, len(literals) <= maxKeywordVariants
+	}
+	return nil, false
+}
+
+// classLiterals converts a character class of a few characters e.g. [Aa] or [pousr] to distinct lower case characters
+func classLiterals(ranges []rune) ([]string, bool) {
+	var literals []string
+	for idx := 0; idx+1 < len(ranges); idx += 2 {
+		if ranges[idx+1]-ranges[idx] >= maxKeywordVariants {
+			return nil, false
+		}
+		for r := ranges[idx]; r <= ranges[idx+1]; r++ {
+			if r > unicode.MaxASCII {
+				return nil, false
+			}
+			literals = union(literals, []string{strings.ToLower(string(r))})
+		}
+	}
+	return literals, len(literals) > 0 && len(literals) <= maxKeywordVariants
+}
+
+// requiredLiterals returns lower case literals such that every match of a regex contains at least one of them
+func requiredLiterals(re *syntax.Regexp) ([]string, bool) {
+	if literals, ok := exactLiterals(re); ok && shortest(literals) >= minKeywordLength {
+		return literals, true
+	}
+
+	switch re.Op {
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpAlternate:
+		var literals []string
+		for _, sub := range re.Sub {
+			subLiterals, ok := requiredLiterals(sub)
+			if !ok {
+				return nil, false
+			}
+			literals = union(literals, subLiterals)
+		}
+		return literals, len(literals) <= maxKeywordVariants
+	case syntax.OpConcat:
+		var best []string
+		consider := func(literals []string) {
+			if shortest(literals) >= minKeywordLength && better(literals, best) {
+				best = literals
+			}
+		}
+
+		// runs of consecutive factors matching exact literals are merged into longer literals
+		run := []string{""}
+		for _, sub := range flattenConcat(re) {
+			if literals, ok := exactLiterals(sub); ok {
+				if merged := product(run, literals); merged != nil {
+					run = merged
+					continue
+				}
+				consider(run)
+				run = literals
+				continue
+			}
+
+			consider(run)
+			run = []string{""}
+			if literals, ok := requiredLiterals(sub); ok {
+				consider(literals)
+			}
+		}
+		consider(run)
+		return best, best != nil
+	}
+	return nil, false
+}
+
+// flattenConcat returns factors of nested concatenations, which are produced by simplification of repetitions
+func flattenConcat(re *syntax.Regexp) []*syntax.Regexp {
+	if re.Op != syntax.OpConcat {
+		return []*syntax.Regexp{re}
+	}
+
+	var factors []*syntax.Regexp
+	for _, sub := range re.Sub {
+		factors = append(factors, flattenConcat(sub)...)
+	}
+	return factors
+}
+
+// better prefers literals with a longer shortest literal and then fewer alternatives
+func better(literals []string, than []string) bool {
+	if than == nil {
+		return true
+	}
+	if shortest(literals) != shortest(than) {
+		return shortest(literals) > shortest(than)
+	}
+	return len(literals) < len(than)
+}
+
+func shortest(literals []string) int {
+	min := -1
+	for _, literal := range literals {
+		if min < 0 || len(literal) < min {
+			min = len(literal)
+		}
+	}
+	return min
+}
+
+// product concatenates every prefix with every suffix, nil when there are too many combinations
+func product(prefixes []string, suffixes []string) []string {
+	if len(prefixes)*len(suffixes) > maxKeywordVariants {
+		return nil
+	}
+
+	var literals []string
+	for _, prefix := range prefixes {
+		for _, suffix := range suffixes {
+			literals = union(literals, []string{prefix + suffix})
+		}
+	}
+	return literals
+}
+
+func union(literals []string, added []string) []string {
+	for _, literal := range added {
+		found := false
+		for _, existing := range literals {
+			if existing == literal {
+				found = true
+				break
+			}
+		}
+		if !found {
+			literals = append(literals, literal)
+		}
+	}
+	return literals
+}
+
+func isASCII(s string) bool {
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lowerASCII lowers ASCII letters only, like the automaton does
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for idx, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[idx] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func TestAhoCorasickMatchesBruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	word := func(alphabet string, n int) string {
+		var b strings.Builder
+		for cnt := 0; cnt < n; cnt++ {
+			b.WriteByte(alphabet[rnd.Intn(len(alphabet))])
+		}
+		return b.String()
+	}
+
+	// a small alphabet makes keywords overlap, be prefixes and suffixes of each other and repeat in texts
+	for run := 0; run < 50; run++ {
+		keywords := []string{"ab", "abab", "b", "bab"}
+		for cnt := 0; cnt < 10; cnt++ {
+			keywords = append(keywords, word("abc", 1+rnd.Intn(5)))
+		}
+		ac := newAhoCorasick(keywords)
+
+		for cnt := 0; cnt < 20; cnt++ {
+			text := word("abcABC\xc3\x81 ", rnd.Intn(60))
+
+			found := make([]int, len(keywords))
+			ac.scan(text, func(keyword int) { found[keyword]++ })
+
+			lower := lowerASCII(text)
+			for idx, keyword := range keywords {
+				var want int
+				for pos := 0; pos+len(keyword) <= len(lower); pos++ {
+					if lower[pos:pos+len(keyword)] == keyword {
+						want++
+					}
+				}
+				if found[idx] != want {
+					t.Errorf("keyword %q found %d times in %q, want %d", keyword, found[idx], text, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPrefilterResults(t *testing.T) {
+	for _, files := range [][]string{nil, {"../patterns/high-confidence.yaml"}} {
+		patterns, err := LoadPatterns(files, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		corpus := syntheticCorpus(2000)
+		for _, pattern := range patterns.Get() {
+			corpus = append(corpus, pattern.Positive...)
+			corpus = append(corpus, pattern.Negative...)
+		}
+
+		forced := true
+		app := &App{patterns: patterns, forceFlg: &forced, stopwords: defaultStopwords}
+		baseline := benchScan(app, corpus)
+
+		app.prefilter = newPrefilter(patterns.Get())
+		if cnt := differences(baseline, benchScan(app, corpus)); cnt > 0 {
+			t.Errorf("%v: keyword prefiltering changed results of %d lines", files, cnt)
+		}
+
+		// a pattern matching a line is never filtered out, even when its match is not reported
+		for _, line := range corpus {
+			selected := app.prefilter.candidates(line)
+			for idx, pattern := range patterns.Get() {
+				if !selected[idx] && len(pattern.CompiledRegex.FindStringSubmatch(line)) > 0 {
+					t.Errorf("%v: pattern %q matching %q was filtered out", files, pattern.Name, line)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+//go:build linux && amd64
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// I/O scheduling classes of ioprio_set
+const (
+	ioprioClassBestEffort = 2
+	ioprioClassIdle       = 3
+	ioprioClassShift      = 13
+	ioprioWhoProcess      = 1
+)
+
+var ioClasses = map[string]int{
+	"best-effort": ioprioClassBestEffort,
+	"idle":        ioprioClassIdle,
+}
+
+// threads returns IDs of all threads of the process, scheduling priorities on Linux are per thread
+func threads() []int {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return []int{os.Getpid()}
+	}
+
+	var tids []int
+	for _, entry := range entries {
+		if tid, err := strconv.Atoi(entry.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+	return tids
+}
+
+// setIOPriority sets the I/O scheduling class and level of all threads, threads created later inherit it
+func setIOPriority(class string, level int) error {
+	classID, ok := ioClasses[class]
+	if !ok {
+		return fmt.Errorf("unknown I/O scheduling class %q, expected best-effort or idle", class)
+	}
+	if level < 0 || level > 7 {
+		return fmt.Errorf("I/O priority level %d is not in the range from 0 to 7", level)
+	}
+
+	// the level is ignored by the idle class
+	ioprio := classID<<ioprioClassShift | level
+	for _, tid := range threads() {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
+
+// setNice sets the nice level of all threads, threads created later inherit it
+func setNice(nice int) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("nice level %d is not in the range from -20 to 19", nice)
+	}
+
+	for _, tid := range threads() {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
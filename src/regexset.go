@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/binary"
+	"regexp/syntax"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// maximum number of states cached by a regex set, the cache is cleared when it grows larger
+const maxSetStates = 10000
+
+// RegexSet finds all regexes of a set matching a text in a single pass. Compiled programs of the regexes are merged
+// and simulated together by a lazily built DFA, whose states are sets of instructions of all programs. A state
+// records the regexes that matched when it was reached, so the text is read once however many regexes there are.
+type RegexSet struct {
+	insts    []syntax.Inst  // instructions of all regexes, their Out and Arg refer to this slice
+	starts   []uint32       // first instruction of every regex
+	regexes  []int          // index of the pattern of every regex
+	matchOf  []int32        // index of the regex of every match instruction, -1 for other instructions
+	members  []bool         // flags of patterns included in the set
+	assertOp syntax.EmptyOp // empty-width assertions used by the regexes, other context flags are ignored
+	caches   sync.Pool      // *setCache, states are built by every scanning goroutine on its own
+}
+
+// setState is a set of instructions waiting for the next rune
+type setState struct {
+	insts   []uint32
+	closure [64]*setClosure // closures indexed by context flags of a position
+}
+
+// setClosure is a state followed through all instructions not consuming runes, for given context flags
+type setClosure struct {
+	runes   []uint32 // instructions consuming runes
+	matches []int32  // regexes matching at the position
+	ascii   [utf8.RuneSelf]*setState
+	other   map[rune]*setState
+}
+
+// setCache holds states of a regex set built by a goroutine
+type setCache struct {
+	set    *RegexSet
+	states map[string]*setState
+	start  *setState
+	marks  []uint32 // generation of the last visit of every instruction
+	gen    uint32
+	stack  []uint32
+	next   []uint32
+	key    []byte
+}
+
+// combinable checks whether a pattern can be matched by a regex set, regexes of the backtracking engine cannot
+func combinable(pattern *Pattern) bool {
+	return pattern.Engine != enginePCRE
+}
+
+// newRegexSet builds a set of regexes of patterns, wrap adapts a regex e.g. to multi-line mode. Patterns whose
+// regexes cannot be combined are left out and have to be evaluated one by one.
+func newRegexSet(patterns []Pattern, wrap func(regex string) string) *RegexSet {
+	s := &RegexSet{members: make([]bool, len(patterns))}
+
+	for idx := range patterns {
+		if !combinable(&patterns[idx]) {
+			continue
+		}
+		re, err := syntax.Parse(wrap(patterns[idx].Regex), syntax.Perl)
+		if err != nil {
+			continue
+		}
+		prog, err := syntax.Compile(re.Simplify())
+		if err != nil {
+			continue
+		}
+
+		offset := uint32(len(s.insts))
+		for _, inst := range prog.Inst {
+			inst.Out += offset
+			if inst.Op == syntax.InstAlt || inst.Op == syntax.InstAltMatch {
+				inst.Arg += offset
+			}
+			if inst.Op == syntax.InstEmptyWidth {
+				s.assertOp |= syntax.EmptyOp(inst.Arg)
+			}
+
+			matchOf := int32(-1)
+			if inst.Op == syntax.InstMatch {
+				matchOf = int32(len(s.regexes))
+			}
+			s.insts = append(s.insts, inst)
+			s.matchOf = append(s.matchOf, matchOf)
+		}
+
+		s.starts = append(s.starts, uint32(prog.Start)+offset)
+		s.regexes = append(s.regexes, idx)
+		s.members[idx] = true
+	}
+
+	s.caches.New = func() interface{} { return s.newCache() }
+	return s
+}
+
+func (s *RegexSet) newCache() *setCache {
+	c := &setCache{set: s, marks: make([]uint32, len(s.insts))}
+	c.reset()
+	return c
+}
+
+// Covered returns the number of patterns in the set
+func (s *RegexSet) Covered() int {
+	return len(s.regexes)
+}
+
+// filter unselects patterns of the set that do not match a text, patterns outside of the set stay selected.
+// A nil selection selects all patterns.
+func (s *RegexSet) filter(text string, selected []bool) []bool {
+	if selected == nil {
+		selected = make([]bool, len(s.members))
+		for idx := range selected {
+			selected[idx] = true
+		}
+	}
+
+	// the text is not read when none of the selected patterns is in the set
+	pending := 0
+	for _, idx := range s.regexes {
+		if selected[idx] {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return selected
+	}
+
+	matched := make([]bool, len(s.regexes))
+	c := s.caches.Get().(*setCache)
+	defer s.caches.Put(c)
+
+	state := c.start
+	prev := rune(-1)
+	for pos := 0; ; {
+		r, size := rune(-1), 0
+		if pos < len(text) {
+			if r, size = rune(text[pos]), 1; r >= utf8.RuneSelf {
+				r, size = utf8.DecodeRuneInString(text[pos:])
+			}
+		}
+
+		closure := c.closure(state, syntax.EmptyOpContext(prev, r)&s.assertOp)
+		for _, regex := range closure.matches {
+			if !matched[regex] {
+				matched[regex] = true
+				if selected[s.regexes[regex]] {
+					pending--
+				}
+			}
+		}
+		if size == 0 || pending == 0 {
+			break
+		}
+
+		state = c.step(closure, r)
+		prev = r
+		pos += size
+	}
+
+	for regex, idx := range s.regexes {
+		if !matched[regex] {
+			selected[idx] = false
+		}
+	}
+	return selected
+}
+
+// reset drops all cached states
+func (c *setCache) reset() {
+	c.states = map[string]*setState{}
+	c.start = c.state(nil)
+}
+
+// state returns the cached state of a sorted set of instructions
+func (c *setCache) state(insts []uint32) *setState {
+	c.key = c.key[:0]
+	for _, inst := range insts {
+		c.key = binary.LittleEndian.AppendUint32(c.key, inst)
+	}
+	if state, ok := c.states[string(c.key)]; ok {
+		return state
+	}
+
+	state := &setState{insts: append([]uint32{}, insts...)}
+	c.states[string(c.key)] = state
+	return state
+}
+
+// closure follows instructions of a state and starts of all regexes through instructions not consuming runes,
+// starts are added at every position since regexes can match anywhere in a text
+func (c *setCache) closure(state *setState, flags syntax.EmptyOp) *setClosure {
+	if closure := state.closure[flags]; closure != nil {
+		return closure
+	}
+
+	c.gen++
+	closure := &setClosure{}
+	c.stack = append(append(c.stack[:0], state.insts...), c.set.starts...)
+	for len(c.stack) > 0 {
+		pc := c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+		if c.marks[pc] == c.gen {
+			continue
+		}
+		c.marks[pc] = c.gen
+
+		inst := &c.set.insts[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			c.stack = append(c.stack, inst.Arg, inst.Out)
+		case syntax.InstCapture, syntax.InstNop:
+			c.stack = append(c.stack, inst.Out)
+		case syntax.InstEmptyWidth:
+			if syntax.EmptyOp(inst.Arg)&^flags == 0 {
+				c.stack = append(c.stack, inst.Out)
+			}
+		case syntax.InstMatch:
+			closure.matches = append(closure.matches, c.set.matchOf[pc])
+		case syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+			closure.runes = append(closure.runes, pc)
+		}
+	}
+
+	state.closure[flags] = closure
+	return closure
+}
+
+// step returns the state following a closure after a rune
+func (c *setCache) step(closure *setClosure, r rune) *setState {
+	if r < utf8.RuneSelf {
+		if next := closure.ascii[r]; next != nil {
+			return next
+		}
+	} else if next, ok := closure.other[r]; ok {
+		return next
+	}
+
+	c.next = c.next[:0]
+	for _, pc := range closure.runes {
+		if inst := &c.set.insts[pc]; matchesRune(inst, r) {
+			c.next = append(c.next, inst.Out)
+		}
+	}
+	sort.Slice(c.next, func(i, j int) bool { return c.next[i] < c.next[j] })
+	c.next = uniqueInsts(c.next)
+
+	// states of a cleared cache are not reachable anymore, but the closure being stepped from is still used
+	if len(c.states) >= maxSetStates {
+		c.reset()
+	}
+	next := c.state(c.next)
+
+	if r < utf8.RuneSelf {
+		closure.ascii[r] = next
+	} else {
+		if closure.other == nil {
+			closure.other = map[rune]*setState{}
+		}
+		closure.other[r] = next
+	}
+	return next
+}
+
+// matchesRune checks whether an instruction consumes a rune, the same way as the regexp package does
+func matchesRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRune:
+		return inst.MatchRune(r)
+	case syntax.InstRune1:
+		return r == inst.Rune[0]
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return false
+}
+
+// uniqueInsts removes duplicates from a sorted slice of instructions
+func uniqueInsts(insts []uint32) []uint32 {
+	unique := insts[:0]
+	for idx, inst := range insts {
+		if idx == 0 || inst != insts[idx-1] {
+			unique = append(unique, inst)
+		}
+	}
+	return unique
+}
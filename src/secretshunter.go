@@ -17,14 +17,18 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"github.com/meryemchafry/go-cpulimit"
 	"github.com/schollz/progressbar/v3"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -48,10 +52,48 @@ This code includes third-party packages that are subject to their respective lic
 Please review these licenses before using this code or these packages in your own projects.
 `
 
+const (
+	severityHigh   = "high"
+	severityMedium = "medium"
+	severityLow    = "low"
+)
+
 type Secret struct {
 	SecretType  string
 	SecretValue string
-	LineNumber  int
+	LineNumber  int               // 0 when the line number is unknown
+	Offset      int64             // byte offset of the line of the secret, set only when its line number is unknown
+	Column      int               // position of the secret in its line, set only by the buffered scan
+	Encoding    string            // chain of decodings applied to reveal the secret, empty if found in plain text
+	Severity    string            // initialized with confidence of a pattern, analysers can adjust it
+	Attributes  map[string]string // details about the secret added by analysers
+	Key         *KeyInfo          // set when the secret is a parsed private key
+	Hash        *PasswordHash     // set when the secret is a password hash
+	JWT         *JWTInfo          // set when the secret is a decodable JSON Web Token
+	Pattern     *Pattern          // pattern that matched the secret
+}
+
+// newSecret creates a secret for a match of a pattern, matches failing validation of the pattern are downgraded
+func newSecret(pattern *Pattern, match string, line int) Secret {
+	secret := Secret{SecretType: pattern.Name, SecretValue: match, LineNumber: line, Severity: pattern.Confidence, Pattern: pattern}
+	if !pattern.validate(match) {
+		secret.Severity = severityLow
+		secret.SetAttribute("validation", "failed "+pattern.Validator)
+	}
+	return secret
+}
+
+func (secret *Secret) SetAttribute(name string, value string) {
+	if secret.Attributes == nil {
+		secret.Attributes = map[string]string{}
+	}
+	secret.Attributes[name] = value
+}
+
+// post-match analysers that enrich found secrets with additional information
+var analysers = []func(file string, secret *Secret){
+	enrichPrivateKey,
+	enrichJWT,
 }
 
 type ScanResults struct {
@@ -59,37 +101,124 @@ type ScanResults struct {
 	secrets map[int]Secret
 }
 
+// Report collects results of all stages of a scan
+type Report struct {
+	scans         []*ScanResults
+	secretsFound  int
+	skipped       []SkippedFile
+	relations     []KeyRelation
+	weakPasswords []WeakPassword
+}
+
+// stringList is a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 type App struct {
 	fdout            *os.File
-	patternsFile     *string
+	patternsFiles    stringList
+	extendFlg        *bool
+	disableFlag      *string
 	maxNumberOfCpu   *int
 	maxCpuLoadLimit  *int
 	forceFlg         *bool
 	outFile          *string
 	excludePathsFlag *string
+	decodeDepth      *int
+	weakPassFlg      *bool
+	wordlistFile     *string
+	showPassFlg      *bool
+	verifyFlg        *bool
+	verifyURL        *string
+	verifyRate       *int
+	stopwordsFlag    *string
+	stopwords        []string // lower case words marking benign matches
+	stats            Statistics
 	paths            []string
 	directories      []string // directories to scan
 	excludedPaths    []string // directories and patterns to exclude
 	files            []string // files to scan
+	skipped          Skips    // files that are not scanned with reasons
+	aliases          *Aliases // hard links and copies of scanned files
+	dedupFlg         *bool
+	maxSize          *int
+	headTail         *int
+	xdevFlg          *bool
+	maxDepth         *int
+	followFlg        *bool
+	walkers          *int
 	limiter          *cpulimit.Limiter
+	processed        int64 // bytes processed by regexes, consulted by throttle
+	cgroup           CgroupLimits
+	memoryCeiling    *int
+	memoryGuard      *MemoryGuard // nil when there is no memory ceiling
+	readRate         *int
+	ioClass          *string
+	ioLevel          *int
+	niceLevel        *int
+	iowaitLimit      *int
+	readLimiter      *ReadLimiter   // nil when read throughput is not limited
+	iowait           *IOWaitMonitor // nil when scanning does not pause on high I/O wait
 	patterns         *Patterns
+	prefilter        *Prefilter // nil when keyword prefiltering is disabled
+	noPrefilterFlg   *bool
+	combined         *RegexSet // nil when combined matching is disabled
+	combinedFlg      *bool
+	bufferFlg        *bool
+	bufferMax        *int
 	versionFlg       *bool
 	helpFlg          *bool
 }
 
 func NewApp() *App {
-	app := &App{fdout: os.Stdout}
+	app := &App{fdout: os.Stdout, aliases: newAliases()}
 	app.Init()
 
 	return app
 }
 
 func (app *App) Init() {
-	app.patternsFile = flag.String("p", "", "`file` with regular expression patterns of secrets that the tool is\nsupposed to scan found files for - mandatory.\nPatterns can be found on https://github.com/mazen160/secrets-patterns-db")
-	app.maxNumberOfCpu = flag.Int("c", runtime.NumCPU(), "maximum `number of vCPUs` to be used by the tool - optional")
+	flag.Var(&app.patternsFiles, "p", "`file or directory` (with YAML files) with regular expression patterns of\nsecrets that the tool is supposed to scan found files for, can be repeated.\nProvided patterns replace default patterns unless -extend is used - optional.\nPatterns can be found on https://github.com/mazen160/secrets-patterns-db")
+	app.dedupFlg = flag.Bool("dedup", false, "`scans identical files once`, files with the same content are reported together\n- optional")
+	app.extendFlg = flag.Bool("extend", false, "`extends default patterns` with patterns provided with -p, patterns with IDs\nof default patterns override them - optional")
+	app.disableFlag = flag.String("disable", "", "comma seperated `list of pattern IDs` to disable - optional")
+	app.cgroup = detectCgroupLimits()
+	app.maxNumberOfCpu = flag.Int("c", app.cgroup.CPUs(), "maximum `number of vCPUs` to be used by the tool, limited by a cgroup CPU quota\nby default - optional")
 	app.maxCpuLoadLimit = flag.Int("t", 80, "`throttling value` (from 10 to 80), which sets maximum CPU usage that the\nsystem cannot exceed during execution of the tool - optional")
 	app.outFile = flag.String("o", "Stdout", "`output file` for a generated report otherwise the report will be\nprinted to standard output - optional")
 	app.excludePathsFlag = flag.String("x", "", "comma seperated `list of regular expressions and/or files` (with regular\nexpressions) to be used to exclude files or directories during the scan.\nTypically usage is to exclude directories containing documentation, manual\npages or examples.")
+	app.decodeDepth = flag.Int("d", 0, "`decoding depth` for base64, hex and URL encoded strings that are decoded\nand scanned for secrets, 0 disables decoding - optional")
+	app.weakPassFlg = flag.Bool("weak", false, "`checks found password hashes` against default credentials and a wordlist\n- optional")
+	app.wordlistFile = flag.String("wordlist", "", "`file` with passwords, one per line, checked against found password hashes,\nimplies -weak - optional")
+	app.showPassFlg = flag.Bool("showpasswords", false, "`prints passwords` found with -weak in the report - optional")
+	app.stopwordsFlag = flag.String("s", "", "comma seperated `list of stopwords and/or a file` (with stopwords), matches\ncontaining a stopword are suppressed, 'none' disables stopwords - optional")
+	app.verifyFlg = flag.Bool("verify", false, "`verifies found secrets` against live endpoints defined by verifiers of\npatterns - optional")
+	app.verifyURL = flag.String("verifyurl", "", "`base URL` overriding base URLs of all verifiers e.g. a local mock\nendpoint - optional")
+	app.verifyRate = flag.Int("verifyrate", 2, "maximum `number of verification requests` per second - optional")
+	app.noPrefilterFlg = flag.Bool("noprefilter", false, "`disables keyword prefiltering`, which evaluates regexes of patterns only for lines\ncontaining their keywords - optional")
+	app.combinedFlg = flag.Bool("combined", false, "`matches all patterns` supported by Go regexp package in a single pass and\nevaluates only patterns matching a line one by one - optional")
+	app.bufferFlg = flag.Bool("buffer", false, "`scans whole files` at once instead of line by line, which allows patterns\nto match across lines - optional")
+	app.bufferMax = flag.Int("buffermax", defaultBufferMax, "`size in MiB` of files above which files are scanned in chunks in the buffered\nmode - optional")
+	app.memoryCeiling = flag.Int("m", int(float64(app.cgroup.MemoryLimit)*defaultMemoryShare)>>20, "`memory ceiling in MiB`, discovery of files slows down when the heap approaches\nit, 0 disables it, defaults to 80% of a cgroup memory limit - optional")
+	app.readRate = flag.Int("readrate", 0, "maximum read `throughput in MiB/s` of all workers, 0 means unlimited - optional")
+	app.ioClass = flag.String("ioclass", "", "I/O scheduling `class` of the tool, best-effort or idle - optional")
+	app.ioLevel = flag.Int("iolevel", 7, "I/O scheduling `level` (from 0 to 7) of the best-effort class, 7 is the lowest\npriority - optional")
+	app.niceLevel = flag.Int("nice", 0, "`nice level` (from -20 to 19) of the tool, 0 keeps the current level - optional")
+	app.iowaitLimit = flag.Int("iowait", 0, "`I/O wait` (in %) of the system above which scanning pauses, 0 disables pausing\n- optional")
+	app.maxSize = flag.Int("maxsize", 0, "`size in MiB` of files above which files are skipped, 0 means unlimited - optional")
+	app.headTail = flag.Int("headtail", 0, "`size in MiB` of heads and tails of files larger than -maxsize that are scanned\ninstead of skipping these files - optional")
+	app.xdevFlg = flag.Bool("xdev", false, "`stays on file systems` of scanned directories, directories of other mounted file\nsystems are skipped - optional")
+	app.maxDepth = flag.Int("maxdepth", 0, "maximum `depth` of walked directories below scanned directories, 0 means unlimited\n- optional")
+	app.followFlg = flag.Bool("follow", false, "`follows symlinks` to files and directories, symlink loops are detected and skipped\n- optional")
+	app.walkers = flag.Int("walkers", 1, "`number of goroutines` walking directories concurrently, 1 walks directories\nsequentially - optional")
 	app.versionFlg = flag.Bool("v", false, "prints `version information`")
 	app.forceFlg = flag.Bool("f", false, "this flag `forces execution` and inhibits throttling")
 	//app.helpFlg = flag.Bool("h", false, "prints help")
@@ -106,30 +235,113 @@ secretshunter Version RC1.1 Released 08.2023"
 Author: henryk.hruszka@nokia.com
 
 Usage: secretshunter [OPTIONS] "space seperated directories to scan"
+       secretshunter patterns COMMAND [OPTIONS]
 
 secrentshunter, when invoked without any parameters, will use defaults 
 and will scan the whole file system.  
 
 OPTIONS:
+  -buffer scans whole files
+	scans whole files at once instead of line by line, which allows
+	patterns to match across lines
+  -buffermax size in MiB
+	size in MiB of files above which files are scanned in chunks in the
+	buffered mode - default 64
   -c number of vCPUs
-	maximum number of vCPUs to be used by the tool - default (max available)
+	maximum number of vCPUs to be used by the tool - default (max available,
+	limited by a cgroup CPU quota)
+  -combined matches all patterns
+	matches all patterns supported by Go regexp package in a single pass
+	with a set-matching automaton, only patterns matching a line are
+	evaluated one by one
+  -d decoding depth
+	decoding depth for base64, hex and URL encoded strings that are decoded
+	and scanned for secrets, 0 disables decoding - default 0
+  -dedup scans identical files once
+	hashes content of files of equal sizes and scans identical files once,
+	secrets are reported once with all paths sharing the content. Hard
+	links are always scanned once
+  -disable list of pattern IDs
+	comma seperated list of IDs of patterns to disable
+  -extend extends default patterns
+	extends default patterns with patterns provided with -p, patterns with
+	IDs of default patterns override them
+  -follow follows symlinks
+	follows symlinks to files and directories, symlink loops are detected
+	and skipped
+  -headtail size in MiB
+	size in MiB of heads and tails of files larger than -maxsize, which
+	are scanned instead of skipping these files, secrets in tails are
+	located by byte offsets
+  -ioclass I/O scheduling class
+	I/O scheduling class of the tool, best-effort or idle
+  -iolevel I/O scheduling level
+	I/O scheduling level (from 0 to 7) of the best-effort class, 7 is the
+	lowest priority - default 7
+  -iowait I/O wait in percent
+	I/O wait of the system above which scanning pauses, 0 disables pausing
+  -m memory ceiling in MiB
+	discovery of files slows down when the heap approaches the memory
+	ceiling, 0 disables it - default (80%% of a cgroup memory limit)
+  -maxdepth depth
+	maximum depth of walked directories below scanned directories,
+	0 means unlimited - default 0
+  -maxsize size in MiB
+	size in MiB of files above which files are skipped, 0 means
+	unlimited - default 0
+  -nice nice level
+	nice level (from -20 to 19) of the tool, 0 keeps the current level
+  -noprefilter disables keyword prefiltering
+	disables keyword prefiltering, which evaluates regexes of patterns only
+	for lines containing one of their keywords
   -o output file
 	output file for a generated report otherwise the report will be
 	printed to standard output
-  -p file
-	file with regular expression patterns of secrets that the tool is
-	supposed to scan found files for
+  -p file or directory
+	file or directory (with YAML files) with regular expression patterns of
+	secrets that the tool is supposed to scan found files for, can be
+	repeated. Provided patterns replace default patterns unless -extend
+	is used
 	Patterns can be found on https://github.com/mazen160/secrets-patterns-db
+  -readrate throughput in MiB/s
+	maximum read throughput of all workers, 0 means unlimited
+  -s list of stopwords and/or a file
+	comma seperated list of stopwords and/or a file (with stopwords),
+	matches containing a stopword are suppressed, 'none' disables
+	stopwords - default (example, dummy, xxxx, placeholder)
   -t throttling value
 	throttling value (from 10 to 80), which sets maximum CPU usage that the
 	system cannot exceed during execution of the tool - (default 65)
+  -showpasswords
+	prints passwords found with -weak in the report, otherwise passwords
+	are never printed
   -v version information
 	prints version information
+  -verify
+	verifies found secrets against live endpoints defined by verifiers
+	of patterns, secrets are labelled verified, invalid or unknown
+  -verifyrate number of verification requests
+	maximum number of verification requests per second - default 2
+  -verifyurl base URL
+	base URL overriding base URLs of all verifiers e.g. a local mock
+	endpoint
+  -walkers number of goroutines
+	number of goroutines walking directories concurrently, 1 walks
+	directories sequentially - default 1
+  -weak
+	checks found password hashes against default credentials and
+	a wordlist, checks are throttled as scanning
+  -wordlist file
+	file with passwords, one per line, checked against found password
+	hashes, implies -weak
   -x list of regular expressions and/or files
 	comma seperated list of regular expressions and/or files (with regular
 	expressions) to be used to exclude files or directories during the scan.
 	Typically usage is to exclude directories containing documentation, manual
 	pages or examples.
+  -xdev stays on file systems
+	stays on file systems of scanned directories, directories of other
+	mounted file systems are skipped
 `)
 	os.Exit(2)
 }
@@ -150,21 +362,32 @@ func (app *App) Start() {
 	}
 
 	// check if a minimum set of parameters was passed to the program
-	if len(*app.patternsFile) == 0 {
-		app.patterns, err = DefaultPatterns()
-		if err != nil {
-			log.Fatalf("[!!] Internal application error. Default secrets patterns cannot be initialized due to: %s\n", err.Error())
-		}
-		fmt.Printf("[*] No file with secret patterns provided, using default %d secret patterns\n", app.patterns.Num())
-	} else {
-		if _, err = os.Stat(*app.patternsFile); os.IsNotExist(err) {
-			log.Fatalf("[!!] Provided file with secret patterns cannot be accessed: %s\n", err.Error())
-		}
+	var disabled []string
+	if len(*app.disableFlag) > 0 {
+		disabled = strings.Split(*app.disableFlag, ",")
+	}
 
-		if app.patterns, err = NewPatterns(*app.patternsFile); err != nil {
-			log.Fatalf("[!!] Secret patterns cannot be loaded from the provided file %s due to %s\n", *app.patternsFile, err.Error())
+	if app.patterns, err = LoadPatterns(app.patternsFiles, *app.extendFlg, disabled); err != nil {
+		log.Fatalf("[!!] Secret patterns cannot be loaded due to %s\n", err.Error())
+	}
+	fmt.Printf("[*] Loaded %d secret patterns\n", app.patterns.Num())
+	for _, line := range app.patterns.Summary() {
+		fmt.Printf("\t%s\n", line)
+	}
+
+	if !*app.noPrefilterFlg {
+		app.prefilter = newPrefilter(app.patterns.Get())
+		fmt.Printf("[*] Keyword prefiltering covers %d of %d secret patterns\n", app.prefilter.Covered(), app.patterns.Num())
+	}
+
+	if *app.combinedFlg {
+		// in the buffered mode patterns are matched in multi-line mode against whole files
+		wrap := func(regex string) string { return regex }
+		if *app.bufferFlg {
+			wrap = func(regex string) string { return "(?m:" + regex + ")" }
 		}
-		fmt.Printf("[*] Loaded %d secret patterns from %s file\n", app.patterns.Num(), *app.patternsFile)
+		app.combined = newRegexSet(app.patterns.Get(), wrap)
+		fmt.Printf("[*] Combined matching covers %d of %d secret patterns\n", app.combined.Covered(), app.patterns.Num())
 	}
 
 	if len(app.paths) == 0 {
@@ -177,6 +400,11 @@ func (app *App) Start() {
 		*app.maxCpuLoadLimit = 65
 	}
 
+	if *app.decodeDepth < 0 {
+		log.Printf("[!!] Provided decoding depth %d is not valid. Decoding disabled.\n", *app.decodeDepth)
+		*app.decodeDepth = 0
+	}
+
 	if *app.maxNumberOfCpu < 1 || *app.maxNumberOfCpu > runtime.NumCPU() {
 		log.Printf("[!!] Provided number of %d vCPUs is not valid. Defaulting to the number of vCPUs on the system (%d vCPUs).\n", *app.maxNumberOfCpu, runtime.NumCPU())
 		*app.maxNumberOfCpu = runtime.NumCPU()
@@ -184,6 +412,7 @@ func (app *App) Start() {
 
 	app.verifyPaths()
 	app.verifyExcludedPaths()
+	app.verifyStopwords()
 
 	if len(*app.excludePathsFlag) == 0 {
 		log.Printf("[+] No regular expressions provided for excluding file paths, using defaults ones:\n\t%s", strings.Join(app.excludedPaths, "\n\t"))
@@ -197,6 +426,13 @@ func (app *App) Start() {
 		fmt.Printf("[*] Scan results will be saved to %s file\n", *app.outFile)
 	}
 
+	if app.cgroup.CPUQuota > 0 || app.cgroup.MemoryLimit > 0 {
+		fmt.Printf("[*] Running in a cgroup v%d with CPU quota %s and memory limit %s\n", app.cgroup.Version, describeQuota(app.cgroup.CPUQuota), describeMemoryLimit(app.cgroup.MemoryLimit))
+	}
+	if *app.memoryCeiling > 0 {
+		fmt.Printf("[*] Memory ceiling set to %d MiB\n", *app.memoryCeiling)
+	}
+
 	// limit number of vCPUs used by the program
 	runtime.GOMAXPROCS(*app.maxNumberOfCpu)
 
@@ -207,13 +443,116 @@ func (app *App) Start() {
 		Measurements:    3,                      // use the avg of the last 3 measurements
 	}
 
+	// in a cgroup with a CPU quota usage of the program is limited to the throttling value of the quota,
+	// the limiter measures it as a share of CPU time of the whole host
+	if app.cgroup.CPUQuota > 0 {
+		hostCPUs, err := cpu.Counts(true)
+		if err != nil || hostCPUs < 1 {
+			hostCPUs = runtime.NumCPU()
+		}
+		app.limiter.CurrentProcessOnly = true
+		app.limiter.MaxCPUUsage = float64(*app.maxCpuLoadLimit) * app.cgroup.CPUQuota / float64(hostCPUs)
+	}
+
+	if *app.memoryCeiling < 0 {
+		log.Printf("[!!] Provided memory ceiling %d MiB is not valid. Memory ceiling disabled.\n", *app.memoryCeiling)
+		*app.memoryCeiling = 0
+	}
+	app.memoryGuard = newMemoryGuard(uint64(*app.memoryCeiling)<<20, &app.stats)
+
+	app.verifyIOSettings()
+	app.verifySizeLimits()
+
 	if !*app.forceFlg {
 		_ = app.limiter.Start()
 	}
 }
 
+// verifyIOSettings configures read throughput cap, scheduling priorities and pausing on high I/O wait
+func (app *App) verifyIOSettings() {
+	if *app.readRate < 0 {
+		log.Printf("[!!] Provided read throughput %d MiB/s is not valid. Read throughput is not limited.\n", *app.readRate)
+		*app.readRate = 0
+	}
+	if app.readLimiter = newReadLimiter(int64(*app.readRate) << 20); app.readLimiter != nil {
+		fmt.Printf("[*] Read throughput limited to %d MiB/s\n", *app.readRate)
+	}
+
+	if len(*app.ioClass) > 0 {
+		if err := setIOPriority(*app.ioClass, *app.ioLevel); err != nil {
+			log.Printf("[!!] I/O scheduling class cannot be set due to %s\n", err.Error())
+		}
+	}
+
+	if *app.niceLevel != 0 {
+		if err := setNice(*app.niceLevel); err != nil {
+			log.Printf("[!!] Nice level cannot be set due to %s\n", err.Error())
+		}
+	}
+
+	if *app.iowaitLimit < 0 || *app.iowaitLimit > 100 {
+		log.Printf("[!!] Provided I/O wait %d is not in the range from 0 to 100. Pausing on high I/O wait disabled.\n", *app.iowaitLimit)
+		*app.iowaitLimit = 0
+	}
+	app.iowait = newIOWaitMonitor(*app.iowaitLimit, &app.stats)
+	app.iowait.Start()
+}
+
+// verifySizeLimits validates limits of sizes of scanned files and settings of walking directories
+func (app *App) verifySizeLimits() {
+	if *app.maxSize < 0 {
+		log.Printf("[!!] Provided maximum file size %d MiB is not valid. File sizes are not limited.\n", *app.maxSize)
+		*app.maxSize = 0
+	}
+	if *app.headTail < 0 || (*app.headTail > 0 && *app.maxSize == 0) {
+		log.Printf("[!!] Provided head and tail size %d MiB is not valid or -maxsize is not set. Scanning of heads and tails disabled.\n", *app.headTail)
+		*app.headTail = 0
+	}
+
+	if *app.maxDepth < 0 {
+		log.Printf("[!!] Provided maximum depth %d is not valid. Depth of directories is not limited.\n", *app.maxDepth)
+		*app.maxDepth = 0
+	}
+
+	if *app.walkers < 1 {
+		log.Printf("[!!] Provided number of walkers %d is not valid. Directories will be walked sequentially.\n", *app.walkers)
+		*app.walkers = 1
+	}
+
+	if *app.maxSize > 0 && *app.headTail > 0 {
+		fmt.Printf("[*] Only %d MiB heads and tails of files larger than %d MiB will be scanned\n", *app.headTail, *app.maxSize)
+	} else if *app.maxSize > 0 {
+		fmt.Printf("[*] Files larger than %d MiB will be skipped\n", *app.maxSize)
+	}
+}
+
+// fileListOptions returns options of discovery of files, pseudo file systems are found in the mount table
+func (app *App) fileListOptions() *FileListOptions {
+	excludes := app.excludedPaths
+	mounts, err := readMounts()
+	if err != nil {
+		log.Printf("[!!] Mounted file systems cannot be read due to %s, only /proc and /sys will be skipped\n", err.Error())
+		excludes = append(append([]string{}, excludes...), pseudoExcludePatterns...)
+	}
+
+	return &FileListOptions{
+		excludes:       excludes,
+		maxSize:        int64(*app.maxSize) << 20,
+		headTail:       *app.headTail > 0,
+		guard:          app.memoryGuard,
+		skipped:        &app.skipped,
+		aliases:        app.aliases,
+		oneFilesystem:  *app.xdevFlg,
+		maxDepth:       *app.maxDepth,
+		followSymlinks: *app.followFlg,
+		pseudo:         pseudoDevices(mounts),
+		walkers:        *app.walkers,
+	}
+}
+
 func (app *App) Stop() {
 	_ = app.fdout.Close()
+	app.iowait.Stop()
 	if !*app.forceFlg {
 		app.limiter.Stop()
 	}
@@ -270,26 +609,32 @@ func (app *App) verifyPaths() {
 }
 
 func (app *App) verifyExcludedPaths() {
-	var patterns []string
-
-	if len(*app.excludePathsFlag) > 0 {
-		patterns = strings.Split(*app.excludePathsFlag, ",")
-	} else {
+	if len(*app.excludePathsFlag) == 0 {
 		app.excludedPaths = defaultExcludePatterns
 		return
 	}
 
-	if len(patterns) == 1 {
-		// there is only one pattern provided by a user, check whether this is a file
+	app.excludedPaths = readListOrFile(*app.excludePathsFlag, "path exclusion patterns")
+}
+
+// readListOrFile splits a comma separated list of values, a single value that is a path to an existing file
+// is replaced with lines of this file
+func readListOrFile(value string, description string) []string {
+	var lines []string
 
-		filePath := patterns[0]
+	values := strings.Split(value, ",")
+
+	if len(values) == 1 {
+		// there is only one value provided by a user, check whether this is a file
+
+		filePath := values[0]
 		if _, err := os.Stat(filePath); !os.IsNotExist(err) {
-			// user provided a file with patterns for excluded paths
+			// user provided a file with values
 
 			readFile, err := os.Open(filePath)
 
 			if err != nil {
-				log.Fatalf("[!!] Cannot open file %s with path exclusion patterns due to error: %s. Aborting.\n", filePath, err)
+				log.Fatalf("[!!] Cannot open file %s with %s due to error: %s. Aborting.\n", filePath, description, err)
 			}
 			defer func() { _ = readFile.Close() }()
 
@@ -297,49 +642,98 @@ func (app *App) verifyExcludedPaths() {
 			fileScanner.Split(bufio.ScanLines)
 
 			for fileScanner.Scan() {
-				app.excludedPaths = append(app.excludedPaths, fileScanner.Text())
+				lines = append(lines, fileScanner.Text())
 			}
 
-			return
+			return lines
 		}
 	}
 
-	app.excludedPaths = patterns
+	return values
 }
 
-func (app *App) scanWithRegex(text string) (*Pattern, string) {
-	for _, pattern := range app.patterns.Get() {
-		if match := pattern.CompiledRegex.FindStringSubmatch(text); len(match) > 0 {
-			return &pattern, strings.Clone(match[0])
+func (app *App) scanWithRegex(file string, text string) (*Pattern, string) {
+	var selected []bool
+	if app.prefilter != nil {
+		selected = app.prefilter.candidates(text)
+	}
+	if app.combined != nil {
+		selected = app.combined.filter(text, selected)
+	}
+
+	for idx, pattern := range app.patterns.Get() {
+		if (selected != nil && !selected[idx]) || !pattern.appliesTo(file) {
+			continue
 		}
 
-		if !*app.forceFlg {
-			app.limiter.Wait()
+		// a line can hold several secrets, the first one passing validation and allowlists is reported
+		for _, match := range pattern.findAll(text) {
+			if pattern.discards() && !pattern.validate(match) {
+				continue
+			}
+			if app.suppressed(&pattern, match, text, file) {
+				continue
+			}
+			return &pattern, strings.Clone(match)
 		}
 	}
 	return nil, ""
 }
 
+// scanLine looks for a password hash, a secret matching patterns or an encoded secret in a line of text
+func (app *App) scanLine(file string, text string, line int) (Secret, bool) {
+	if pattern, match := app.scanWithRegex(file, text); pattern != nil {
+		return newSecret(pattern, match, line), true
+	}
+
+	if secret, found := app.scanForHash(file, text, line); found {
+		return secret, true
+	}
+
+	if pattern, match, chain := app.scanDecoded(file, text, *app.decodeDepth, nil); pattern != nil {
+		secret := newSecret(pattern, match, line)
+		secret.Encoding = strings.Join(chain, " > ")
+		return secret, true
+	}
+	return Secret{}, false
+}
+
 func (app *App) scanFile(file string) *ScanResults {
 	f, err := os.Open(file)
 
 	if err != nil && !os.IsNotExist(err) {
 		log.Println(err.Error())
+		app.skipped.addError(file, err)
 		return nil
 	}
 	defer func() { _ = f.Close() }()
 
-	// Splits on newlines by default.
-	scanner := bufio.NewScanner(f)
+	app.stats.addFile()
+
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	var reader io.Reader = f
+	if app.readLimiter != nil {
+		reader = throttledReader{reader: f, limiter: app.readLimiter}
+	}
 
-	line := 1
 	foundSecrets := map[int]Secret{}
+	if *app.headTail > 0 && size > int64(*app.maxSize)<<20 {
+		app.scanHeadTail(file, f, reader, size, foundSecrets)
+	} else if *app.bufferFlg {
+		foundSecrets = app.scanFileBuffered(file, f, reader)
+	} else {
+		app.scanLines(file, reader, 1, 0, foundSecrets)
+	}
 
-	for scanner.Scan() {
-		if pattern, match := app.scanWithRegex(scanner.Text()); pattern != nil {
-			foundSecrets[line] = Secret{SecretType: pattern.Name, SecretValue: match, LineNumber: line}
+	for line, secret := range foundSecrets {
+		for _, analyse := range analysers {
+			analyse(file, &secret)
 		}
-		line++
+		foundSecrets[line] = secret
 	}
 
 	if len(foundSecrets) > 0 {
@@ -349,10 +743,68 @@ func (app *App) scanFile(file string) *ScanResults {
 	}
 }
 
-func (app *App) worker(wg *sync.WaitGroup, jobs chan string, scans chan *ScanResults, bar *progressbar.ProgressBar) {
+// scanLines scans a file line by line, line is the number of the first line. Line 0 means that line numbers are
+// unknown, secrets are then located by byte offsets of their lines counted from offset and keyed by negative indexes
+func (app *App) scanLines(file string, f io.Reader, line int, offset int64, foundSecrets map[int]Secret) {
+	var scanned int64
+	defer func() { app.stats.addScanned(scanned) }()
+
+	// Splits on newlines by default.
+	scanner := bufio.NewScanner(f)
+
+	// annotation found in the previous line
+	var previous *IgnoreAnnotation
+
+	unknown := line == 0
+	key := line
+	if unknown {
+		key = -1
+	}
+
+	for scanner.Scan() {
+		position := offset + scanned
+		scanned += int64(len(scanner.Bytes()) + 1)
+		app.throttle(len(scanner.Bytes()) + 1)
+
+		annotation := parseIgnoreAnnotation(scanner.Text())
+		if annotation != nil {
+			app.stats.addAnnotations(1)
+		}
+
+		if secret, found := app.scanLine(file, scanner.Text(), line); found {
+			if unknown {
+				secret.Offset = position
+			}
+			if annotation.ignores(secret) || previous.ignores(secret) {
+				app.stats.addIgnored(1)
+			} else {
+				foundSecrets[key] = secret
+			}
+		}
+
+		previous = annotation
+		if unknown {
+			key--
+		} else {
+			line++
+			key++
+		}
+	}
+}
+
+func (app *App) worker(id int, pool *workerPool, wg *sync.WaitGroup, jobs chan string, scans chan *ScanResults, bar *progressbar.ProgressBar) {
 	defer wg.Done()
 
-	for file := range jobs {
+	for {
+		pool.wait(id)
+		app.iowait.Wait()
+
+		file, ok := <-jobs
+		if !ok {
+			pool.close()
+			return
+		}
+
 		if scan := app.scanFile(file); scan != nil {
 			scans <- scan
 		}
@@ -368,15 +820,29 @@ func (app *App) ScanFiles(files []string) ([]*ScanResults, int) {
 
 	// calculate how long it took  to scan a file system
 	defer timer("\n[+] Finished scanning files in")()
+	start := time.Now()
+	defer func() { app.stats.setDuration(time.Since(start)) }()
 
 	fmt.Printf("[*] Started scanning %d files.\n", len(files))
 
 	// start progress bar
 	bar := progressbar.Default(int64(len(files)), "Scanning progress")
 
+	// the number of active workers follows the system CPU load unless throttling is inhibited
+	pool := newWorkerPool(cap(jobs))
+	ctx, cancel := context.WithCancel(context.Background())
+	var ag sync.WaitGroup // adapting WaitGroup
+	if !*app.forceFlg {
+		ag.Add(1)
+		go func() {
+			defer ag.Done()
+			app.adaptWorkers(ctx, pool)
+		}()
+	}
+
 	for cnt := 0; cnt < cap(jobs); cnt++ {
 		wg.Add(1)
-		go app.worker(&wg, jobs, scans, bar)
+		go app.worker(cnt, pool, &wg, jobs, scans, bar)
 	}
 
 	var rg sync.WaitGroup // results WaitGroup
@@ -397,8 +863,8 @@ func (app *App) ScanFiles(files []string) ([]*ScanResults, int) {
 
 	// start goroutine which feeds workers with found files
 	go func() {
-		defer close(jobs)
 		defer wg.Done()
+		defer close(jobs)
 
 		for _, file := range files {
 			jobs <- file
@@ -406,56 +872,112 @@ func (app *App) ScanFiles(files []string) ([]*ScanResults, int) {
 	}()
 
 	wg.Wait()
+	cancel()
+	ag.Wait()
 	close(scans)
 	rg.Wait()
 
 	return secrets, secretsCount
 }
 
-func (app *App) GetFiles() (files []string, excludedPaths []string) {
+func (app *App) GetFiles() (files []string) {
+	options := app.fileListOptions()
 
-	files = make([]string, len(app.files))
+	files = make([]string, 0, len(app.files))
+	if *app.dedupFlg {
+		app.aliases.trackSizes()
+	}
 
-	copy(files, app.files)
+	// files provided explicitly are scanned regardless of their types, but not of their sizes
+	for _, file := range app.files {
+		info, err := os.Stat(file)
+		if err == nil && options.tooLarge(info.Size()) {
+			options.skipped.add(file, skipTooLarge)
+			continue
+		}
+		if err == nil {
+			app.aliases.addSize(file, info.Size())
+		}
+		files = append(files, file)
+	}
 
 	// start processing files
 	for _, directory := range app.directories {
 		fmt.Printf("[*] Processing directory %s\n", directory)
 
 		// find plain text files a directory
-		fndfiles, expaths := func() ([]string, []string) {
-			message := fmt.Sprintf("\n[+] Finished scanning %s for files in", directory)
-			defer timer(message)()
-			return getFileList(directory, app.excludedPaths)
-		}()
+		skipped := options.skipped.Num()
+		excluded := options.skipped.Count(skipExcluded)
+		start := time.Now()
+		fndfiles, walked := getFileList(directory, options)
+		app.stats.addDiscovery(directory, len(fndfiles), time.Since(start), walked)
 
 		if len(fndfiles) >= 0 {
-			fmt.Printf("[+] Found %d files in %s\n", len(fndfiles), directory)
+			fmt.Printf("\n[+] Found %d files in %s\n", len(fndfiles), directory)
 			files = append(files, fndfiles...)
 		} else {
 			fmt.Printf("[-] Nothing to scan in %s\n", directory)
 		}
 
-		if len(expaths) > 0 {
-			fmt.Printf("[+] %d paths were excluded based on provided patterns\n", len(expaths))
-			excludedPaths = expaths
+		if count := options.skipped.Count(skipExcluded) - excluded; count > 0 {
+			fmt.Printf("[+] %d paths were excluded based on provided patterns\n", count)
+		}
+		if count := options.skipped.Num() - skipped; count > 0 {
+			fmt.Printf("[+] %d paths were skipped in %s, reasons are listed in the report\n", count, directory)
 		}
 	}
-	return files, excludedPaths
+
+	// hard links of binaries and unreadable files are skipped for the same reasons
+	app.aliases.dropLinks(files, options.skipped)
+
+	if *app.dedupFlg {
+		func() {
+			defer timer("[+] Finished looking for identical files in")()
+			files = app.dedupContent(files)
+		}()
+	}
+	if count := app.aliases.Num(); count > 0 {
+		fmt.Printf("[+] %d links and copies of found files will not be scanned\n", count)
+		app.stats.setDuplicates(int64(count))
+	}
+	return files
 }
 
-func (app *App) GenReport(scans []*ScanResults, secretsFound int, excludedPaths []string) {
+func (app *App) GenReport(report *Report) {
+	scans, secretsFound := report.scans, report.secretsFound
+
 	if len(scans) > 0 {
 		if *app.outFile != "Stdout" {
 			fmt.Printf("[+] Found %d secrets in %d files\n", secretsFound, len(scans))
 		}
 
 		_, _ = fmt.Fprintf(app.fdout, "[+] Found %d secrets in %d files\n", secretsFound, len(scans))
-		// deliver scan results
+		// deliver scan results, expired tokens are reported separately with lower priority
+		var expired []*ScanResults
 		for _, scan := range scans {
-			_, _ = fmt.Fprintf(app.fdout, "[+] Found %d secret(s) in %s file\n", len(scan.secrets), scan.file)
-			for _, secret := range scan.secrets {
-				_, _ = fmt.Fprintf(app.fdout, "\tLine: %d %s: %q\n", secret.LineNumber, secret.SecretType, secret.SecretValue)
+			active, inactive := splitExpired(scan)
+			if inactive != nil {
+				expired = append(expired, inactive)
+			}
+			if active == nil {
+				continue
+			}
+
+			_, _ = fmt.Fprintf(app.fdout, "[+] Found %d secret(s) in %s file\n", len(active.secrets), active.file)
+			app.printAliases(active.file)
+			for _, secret := range active.secrets {
+				app.printSecret(secret)
+			}
+		}
+
+		if len(expired) > 0 {
+			_, _ = fmt.Fprintf(app.fdout, "\n\n[*] Following expired tokens were found, they have lower priority\n")
+			for _, scan := range expired {
+				_, _ = fmt.Fprintf(app.fdout, "[+] Found %d expired token(s) in %s file\n", len(scan.secrets), scan.file)
+				app.printAliases(scan.file)
+				for _, secret := range scan.secrets {
+					app.printSecret(secret)
+				}
 			}
 		}
 
@@ -463,6 +985,9 @@ func (app *App) GenReport(scans []*ScanResults, secretsFound int, excludedPaths
 		// list files with found secrets
 		for _, scan := range scans {
 			_, _ = fmt.Fprintf(app.fdout, "\t%s\n", printFileInfo(scan.file))
+			for _, alias := range app.aliases.Get(scan.file) {
+				_, _ = fmt.Fprintf(app.fdout, "\t%s\n", printFileInfo(alias))
+			}
 		}
 	} else {
 		if *app.outFile != "Stdout" {
@@ -471,17 +996,106 @@ func (app *App) GenReport(scans []*ScanResults, secretsFound int, excludedPaths
 		_, _ = fmt.Fprintf(app.fdout, "[-] No secrets found\n")
 	}
 
-	if len(excludedPaths) > 0 {
-		_, _ = fmt.Fprintf(app.fdout, "\n\n[*] Following paths were excluded from a scan based on the provided patterns\n")
-		for _, exPath := range excludedPaths {
-			_, _ = fmt.Fprintf(app.fdout, "\t%s\n", exPath)
+	if len(report.weakPasswords) > 0 {
+		_, _ = fmt.Fprintf(app.fdout, "\n\n[*] Following accounts use weak or default passwords\n")
+		for _, wp := range report.weakPasswords {
+			password := "<hidden>"
+			if *app.showPassFlg {
+				password = fmt.Sprintf("%q", wp.Password)
+			}
+			location := fmt.Sprintf("%s:%d", wp.File, wp.Line)
+			if wp.Line == 0 {
+				location = fmt.Sprintf("%s offset %d", wp.File, wp.Offset)
+			}
+			_, _ = fmt.Fprintf(app.fdout, "\t%s account: %q %s password from %s: %s\n", location, wp.Account, wp.HashType, wp.Source, password)
+		}
+	}
+
+	if len(report.relations) > 0 {
+		_, _ = fmt.Fprintf(app.fdout, "\n\n[*] Following private keys match certificates or authorized keys\n")
+		// relations are grouped by private keys
+		var lastKey string
+		for _, relation := range report.relations {
+			if key := fmt.Sprintf("%s:%d", relation.KeyFile, relation.KeyLine); key != lastKey {
+				_, _ = fmt.Fprintf(app.fdout, "\t%s %s\n", key, relation.Fingerprint)
+				lastKey = key
+			}
+			_, _ = fmt.Fprintf(app.fdout, "\t\t%s %s:%d %s\n", relation.Kind, relation.File, relation.Line, relation.Details)
+		}
+	}
+
+	app.stats.Print(app.fdout)
+
+	printSkipped(app.fdout, report.skipped)
+}
+
+// splitExpired divides secrets of a scan into active secrets and expired tokens, nil is returned for an empty part
+func splitExpired(scan *ScanResults) (active *ScanResults, expired *ScanResults) {
+	for line, secret := range scan.secrets {
+		part := &active
+		if secret.JWT != nil && secret.JWT.Expired {
+			part = &expired
 		}
+		if *part == nil {
+			*part = &ScanResults{file: scan.file, secrets: map[int]Secret{}}
+		}
+		(*part).secrets[line] = secret
+	}
+	return active, expired
+}
+
+// printAliases lists hard links and copies of a file, which contain the same secrets
+func (app *App) printAliases(file string) {
+	aliases := app.aliases.Get(file)
+	if len(aliases) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(app.fdout, "\tSame content in %d other file(s):\n", len(aliases))
+	for _, alias := range aliases {
+		_, _ = fmt.Fprintf(app.fdout, "\t\t%s\n", alias)
+	}
+}
+
+func (app *App) printSecret(secret Secret) {
+	location := fmt.Sprintf("Line: %d", secret.LineNumber)
+	if secret.LineNumber == 0 {
+		location = fmt.Sprintf("Line: unknown Offset: %d", secret.Offset)
+	}
+
+	if len(secret.Encoding) > 0 {
+		_, _ = fmt.Fprintf(app.fdout, "\t%s %s: %q (decoded: %s)\n", location, secret.SecretType, secret.SecretValue, secret.Encoding)
+	} else if secret.Column > 0 {
+		_, _ = fmt.Fprintf(app.fdout, "\t%s Column: %d %s: %q\n", location, secret.Column, secret.SecretType, secret.SecretValue)
+	} else {
+		_, _ = fmt.Fprintf(app.fdout, "\t%s %s: %q\n", location, secret.SecretType, secret.SecretValue)
+	}
+	app.printAttributes(secret)
+}
+
+func (app *App) printAttributes(secret Secret) {
+	if len(secret.Attributes) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(secret.Attributes))
+	for name := range secret.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	_, _ = fmt.Fprintf(app.fdout, "\t\tseverity: %s\n", secret.Severity)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(app.fdout, "\t\t%s: %s\n", name, secret.Attributes[name])
 	}
 }
 
 func main() {
 	var files []string
-	var excludedPaths []string
+
+	// patterns subcommands do not scan, they work on secret patterns only
+	if len(os.Args) > 1 && os.Args[1] == "patterns" {
+		os.Exit(patternsCommand(os.Args[2:]))
+	}
 
 	app := NewApp()
 	app.Start()
@@ -515,9 +1129,13 @@ func main() {
 	//}
 
 	// look for secrets in found files
-	files, excludedPaths = app.GetFiles()
+	files = app.GetFiles()
 	scans, secretsFound := app.ScanFiles(files)
-	app.GenReport(scans, secretsFound, excludedPaths)
+	report := &Report{scans: scans, secretsFound: secretsFound, skipped: app.skipped.Get()}
+	report.relations = app.Correlate(files, scans)
+	report.weakPasswords = app.CheckPasswords(scans)
+	app.VerifySecrets(scans)
+	app.GenReport(report)
 
 	//if len(scans) > 0 {
 	//	if *app.outFile != "Stdout" {
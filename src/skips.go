@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+// reasons of skipping files, in the order they are reported
+const (
+	skipExcluded    = "excluded"
+	skipPseudoFS    = "pseudo filesystem"
+	skipOtherFS     = "other filesystem"
+	skipMaxDepth    = "max depth"
+	skipSymlinkLoop = "symlink loop"
+	skipTooLarge    = "too large"
+	skipBinary      = "binary"
+	skipPermission  = "permission denied"
+	skipUnreadable  = "unreadable"
+	skipTimeout     = "regex timeout, partially scanned"
+)
+
+var skipReasons = []string{skipExcluded, skipPseudoFS, skipOtherFS, skipMaxDepth, skipSymlinkLoop, skipTooLarge, skipBinary, skipPermission, skipUnreadable, skipTimeout}
+
+// SkippedFile is a file or a directory that was not scanned
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// Skips collects skipped files, it is updated concurrently by discovery and scanning workers
+type Skips struct {
+	mutex sync.Mutex
+	files []SkippedFile
+}
+
+func (s *Skips) add(path string, reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.files = append(s.files, SkippedFile{Path: path, Reason: reason})
+}
+
+// addError records a file that cannot be accessed due to an error
+func (s *Skips) addError(path string, err error) {
+	s.add(path, errorReason(err))
+}
+
+func (s *Skips) Num() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.files)
+}
+
+// Count returns the number of files skipped for a reason
+func (s *Skips) Count(reason string) (count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, file := range s.files {
+		if file.Reason == reason {
+			count++
+		}
+	}
+	return count
+}
+
+// reasons maps skipped paths to reasons of skipping them
+func (s *Skips) reasons() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	reasons := make(map[string]string, len(s.files))
+	for _, file := range s.files {
+		reasons[file.Path] = file.Reason
+	}
+	return reasons
+}
+
+// Get returns skipped files sorted by paths
+func (s *Skips) Get() []SkippedFile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files := make([]SkippedFile, len(s.files))
+	copy(files, s.files)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// printSkipped writes skipped files grouped by reasons to a report
+func printSkipped(w io.Writer, files []SkippedFile) {
+	if len(files) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "\n\n[*] Following %d paths were skipped\n", len(files))
+	for _, reason := range skipReasons {
+		var paths []string
+		for _, file := range files {
+			if file.Reason == reason {
+				paths = append(paths, file.Path)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "\t%s (%d):\n", reason, len(paths))
+		for _, path := range paths {
+			_, _ = fmt.Fprintf(w, "\t\t%s\n", path)
+		}
+	}
+}
+
+// errorReason maps an error of accessing a file to a reason of skipping it
+func errorReason(err error) string {
+	if errors.Is(err, fs.ErrPermission) {
+		return skipPermission
+	}
+	return skipUnreadable
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Discovery describes finding files in a scanned directory
+type Discovery struct {
+	Root     string
+	Files    int
+	Duration time.Duration // walking the directory and detecting types of found files
+	Walk     time.Duration // walking the directory only
+}
+
+// Statistics are updated concurrently by workers scanning files
+type Statistics struct {
+	suppressed  int64 // matches dropped by allowlists and stopwords
+	annotations int64 // inline ignore annotations found in scanned files
+	ignored     int64 // findings suppressed by inline ignore annotations
+	bytes       int64 // bytes of scanned files
+	files       int64 // scanned files
+	duration    int64 // duration of the scan in nanoseconds
+	samples     int64 // measurements of the number of active workers
+	workers     int64 // sum of measured numbers of active workers
+	pauses      int64 // pauses of discovery of files due to the memory ceiling
+	iowait      int64 // pauses of scanning due to high I/O wait
+	partial     int64 // files of which only heads and tails were scanned
+	duplicates  int64 // hard links and copies of scanned files that were not scanned
+
+	discoveries []Discovery // appended only by the main goroutine before scanning
+}
+
+func (s *Statistics) addSuppressed(n int64) {
+	atomic.AddInt64(&s.suppressed, n)
+}
+
+func (s *Statistics) Suppressed() int64 {
+	return atomic.LoadInt64(&s.suppressed)
+}
+
+func (s *Statistics) addAnnotations(n int64) {
+	atomic.AddInt64(&s.annotations, n)
+}
+
+func (s *Statistics) Annotations() int64 {
+	return atomic.LoadInt64(&s.annotations)
+}
+
+func (s *Statistics) addIgnored(n int64) {
+	atomic.AddInt64(&s.ignored, n)
+}
+
+func (s *Statistics) Ignored() int64 {
+	return atomic.LoadInt64(&s.ignored)
+}
+
+func (s *Statistics) addScanned(bytes int64) {
+	atomic.AddInt64(&s.bytes, bytes)
+}
+
+func (s *Statistics) addFile() {
+	atomic.AddInt64(&s.files, 1)
+}
+
+func (s *Statistics) setDuration(duration time.Duration) {
+	atomic.StoreInt64(&s.duration, int64(duration))
+}
+
+func (s *Statistics) addWorkerSample(active int) {
+	atomic.AddInt64(&s.samples, 1)
+	atomic.AddInt64(&s.workers, int64(active))
+}
+
+func (s *Statistics) addMemoryPause() {
+	atomic.AddInt64(&s.pauses, 1)
+}
+
+func (s *Statistics) addIOWaitPause() {
+	atomic.AddInt64(&s.iowait, 1)
+}
+
+func (s *Statistics) addPartial() {
+	atomic.AddInt64(&s.partial, 1)
+}
+
+func (s *Statistics) setDuplicates(n int64) {
+	atomic.StoreInt64(&s.duplicates, n)
+}
+
+func (s *Statistics) addDiscovery(root string, files int, duration time.Duration, walk time.Duration) {
+	s.discoveries = append(s.discoveries, Discovery{Root: root, Files: files, Duration: duration, Walk: walk})
+}
+
+// Throughput returns scanned MiB per second
+func (s *Statistics) Throughput() float64 {
+	duration := time.Duration(atomic.LoadInt64(&s.duration))
+	if duration <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&s.bytes)) / (1 << 20) / duration.Seconds()
+}
+
+// Print writes statistics to a report
+func (s *Statistics) Print(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "\n\n[*] Statistics\n")
+	_, _ = fmt.Fprintf(w, "\tMatches suppressed by allowlists and stopwords: %d\n", s.Suppressed())
+	_, _ = fmt.Fprintf(w, "\tInline ignore annotations found: %d\n", s.Annotations())
+	_, _ = fmt.Fprintf(w, "\tFindings suppressed by inline ignore annotations: %d\n", s.Ignored())
+	for _, discovery := range s.discoveries {
+		_, _ = fmt.Fprintf(w, "\tFound %d files in %s in %s, walking directories took %s\n", discovery.Files, discovery.Root, discovery.Duration.Round(time.Millisecond), discovery.Walk.Round(time.Millisecond))
+	}
+	_, _ = fmt.Fprintf(w, "\tScanned data: %.1f MiB in %d files in %s\n", float64(atomic.LoadInt64(&s.bytes))/(1<<20), atomic.LoadInt64(&s.files), time.Duration(atomic.LoadInt64(&s.duration)).Round(time.Millisecond))
+	_, _ = fmt.Fprintf(w, "\tThroughput: %.1f MiB/s\n", s.Throughput())
+	if samples := atomic.LoadInt64(&s.samples); samples > 0 {
+		_, _ = fmt.Fprintf(w, "\tAverage number of active workers: %.1f\n", float64(atomic.LoadInt64(&s.workers))/float64(samples))
+	}
+	if pauses := atomic.LoadInt64(&s.pauses); pauses > 0 {
+		_, _ = fmt.Fprintf(w, "\tDiscovery of files paused due to the memory ceiling: %d times\n", pauses)
+	}
+	if partial := atomic.LoadInt64(&s.partial); partial > 0 {
+		_, _ = fmt.Fprintf(w, "\tFiles with only heads and tails scanned: %d\n", partial)
+	}
+	if duplicates := atomic.LoadInt64(&s.duplicates); duplicates > 0 {
+		_, _ = fmt.Fprintf(w, "\tHard links and copies of scanned files not scanned: %d\n", duplicates)
+	}
+	if pauses := atomic.LoadInt64(&s.iowait); pauses > 0 {
+		_, _ = fmt.Fprintf(w, "\tScanning paused due to high I/O wait: %d times\n", pauses)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"github.com/shirou/gopsutil/v3/cpu"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// number of bytes processed by regexes after which the CPU limiter is consulted
+const throttleBudget = 1 << 20
+
+// interval of measurements of system CPU load used to resize the worker pool
+const loadMeasureInterval = time.Second
+
+// workers are added when the system CPU load drops this number of percent below the throttling value
+const loadHysteresis = 10
+
+// throttle accounts bytes processed by regexes and consults the CPU limiter and the I/O wait monitor once
+// per budget of bytes
+func (app *App) throttle(n int) {
+	total := atomic.AddInt64(&app.processed, int64(n))
+	if total/throttleBudget != (total-int64(n))/throttleBudget {
+		if !*app.forceFlg {
+			app.limiter.Wait()
+		}
+		app.iowait.Wait()
+	}
+}
+
+// workerPool parks workers above the number of active workers
+type workerPool struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	active int
+	max    int
+	closed bool // all jobs were taken, parked workers have to finish
+}
+
+func newWorkerPool(max int) *workerPool {
+	pool := &workerPool{active: max, max: max}
+	pool.cond = sync.NewCond(&pool.mutex)
+	return pool
+}
+
+// wait blocks a worker until it is active
+func (pool *workerPool) wait(id int) {
+	pool.mutex.Lock()
+	for id >= pool.active && !pool.closed {
+		pool.cond.Wait()
+	}
+	pool.mutex.Unlock()
+}
+
+// close releases all parked workers, it is called once the jobs channel is closed and drained so that the
+// remaining jobs are not processed by more workers than the active ones
+func (pool *workerPool) close() {
+	pool.mutex.Lock()
+	pool.closed = true
+	pool.cond.Broadcast()
+	pool.mutex.Unlock()
+}
+
+// resize changes the number of active workers by delta, at least one worker stays active
+func (pool *workerPool) resize(delta int) int {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.active += delta
+	if pool.active < 1 {
+		pool.active = 1
+	}
+	if pool.active > pool.max {
+		pool.active = pool.max
+	}
+	pool.cond.Broadcast()
+	return pool.active
+}
+
+// measureLoad returns CPU load of the cgroup relative to its quota when the process runs in a cgroup with
+// a CPU quota and the system CPU load otherwise, a measurement interrupted by cancelling ctx fails
+func (app *App) measureLoad(ctx context.Context) (float64, bool) {
+	if app.cgroup.CPUQuota > 0 {
+		return app.cgroup.Load(ctx, loadMeasureInterval)
+	}
+
+	load, err := cpu.PercentWithContext(ctx, loadMeasureInterval, false)
+	if err != nil || len(load) == 0 {
+		sleep(ctx, loadMeasureInterval)
+		return 0, false
+	}
+	return load[0], true
+}
+
+// sleep waits for an interval and returns false when ctx is cancelled earlier
+func sleep(ctx context.Context, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// adaptWorkers shrinks the worker pool when the system CPU load exceeds the throttling value and grows it when
+// the load drops below it, until ctx is cancelled
+func (app *App) adaptWorkers(ctx context.Context, pool *workerPool) {
+	limit := float64(*app.maxCpuLoadLimit)
+
+	for ctx.Err() == nil {
+		load, ok := app.measureLoad(ctx)
+		if !ok {
+			continue
+		}
+
+		var active int
+		switch {
+		case load > limit:
+			active = pool.resize(-1)
+		case load < limit-loadHysteresis:
+			active = pool.resize(1)
+		default:
+			active = pool.resize(0)
+		}
+		app.stats.addWorkerSample(active)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestWorkerPoolKeepsWorkersParkedUntilJobsAreTaken(t *testing.T) {
+	pool := newWorkerPool(4)
+	pool.resize(-3)
+
+	jobs := make(chan int, 100)
+	for job := 0; job < cap(jobs); job++ {
+		jobs <- job
+	}
+	close(jobs)
+
+	// workers take jobs like App.worker does
+	var wg sync.WaitGroup
+	taken := make([]int, pool.max)
+	for id := range taken {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for {
+				pool.wait(id)
+				if _, ok := <-jobs; !ok {
+					pool.close()
+					return
+				}
+				taken[id]++
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if taken[0] != cap(jobs) {
+		t.Errorf("active worker took %d jobs, parked workers %v, want all %d jobs taken by the active one", taken[0], taken[1:], cap(jobs))
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// possible values of on_invalid setting of a pattern
+const (
+	onInvalidDiscard   = "discard"
+	onInvalidDowngrade = "downgrade"
+)
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	githubTokenRegex = regexp.MustCompile(`gh[pousr]_([A-Za-z0-9]{30})([A-Za-z0-9]{6})`)
+	jwtRegex         = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	slackTokenRegex  = regexp.MustCompile(`xox[abposre]-[0-9A-Za-z-]+`)
+	cardNumberRegex  = regexp.MustCompile(`[0-9](?:[ -]?[0-9]){12,18}`)
+	awsKeyRegex      = regexp.MustCompile(`(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}`)
+)
+
+// validators check structure of a matched secret without any network access, they are referenced
+// by name from the validator setting of a pattern
+var validators = map[string]func(match string) bool{
+	"github_crc32":   validateGitHubToken,
+	"jwt":            validateJWT,
+	"luhn":           validateLuhn,
+	"slack":          validateSlackToken,
+	"aws_access_key": validateAWSAccessKey,
+}
+
+// validatorNames returns sorted names of validators
+func validatorNames() []string {
+	names := make([]string, 0, len(validators))
+	for name := range validators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkValidator verifies that validator settings of a pattern are correct
+func (p *Pattern) checkValidator() error {
+	if len(p.Validator) > 0 {
+		if _, ok := validators[p.Validator]; !ok {
+			return fmt.Errorf("pattern %q uses unknown validator %q, known validators: %s", p.Name, p.Validator, strings.Join(validatorNames(), ", "))
+		}
+	}
+
+	switch p.OnInvalid {
+	case "", onInvalidDiscard, onInvalidDowngrade:
+		return nil
+	default:
+		return fmt.Errorf("pattern %q has invalid on_invalid value %q, expected %s or %s", p.Name, p.OnInvalid, onInvalidDiscard, onInvalidDowngrade)
+	}
+}
+
+// validate runs a validator of a pattern on a match, matches of patterns without a validator are always valid
+func (p *Pattern) validate(match string) bool {
+	if validate, ok := validators[p.Validator]; ok {
+		return validate(match)
+	}
+	return true
+}
+
+// discards tells whether a match failing validation should be dropped rather than downgraded
+func (p *Pattern) discards() bool {
+	return p.OnInvalid != onInvalidDowngrade
+}
+
+func base62(value uint32, width int) string {
+	var out []byte
+	for ; value > 0; value /= 62 {
+		out = append([]byte{base62Alphabet[value%62]}, out...)
+	}
+	for len(out) < width {
+		out = append([]byte{'0'}, out...)
+	}
+	return string(out)
+}
+
+// validateGitHubToken checks CRC32 checksum encoded in the last 6 characters of GitHub tokens
+func validateGitHubToken(match string) bool {
+	for _, token := range githubTokenRegex.FindAllStringSubmatch(match, -1) {
+		if base62(crc32.ChecksumIEEE([]byte(token[1])), 6) == token[2] {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJWTPart decodes a base64url encoded JSON object of a JWT
+func decodeJWTPart(part string) (map[string]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	var obj map[string]interface{}
+	if err = json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// validateJWT checks that a token has a decodable header with an algorithm and a decodable payload
+func validateJWT(match string) bool {
+	for _, token := range jwtRegex.FindAllString(match, -1) {
+		parts := strings.Split(token, ".")
+		header, err := decodeJWTPart(parts[0])
+		if err != nil {
+			continue
+		}
+		if _, ok := header["alg"].(string); !ok {
+			continue
+		}
+		if _, err = decodeJWTPart(parts[1]); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// validateLuhn checks the Luhn checksum of payment card numbers, spaces and dashes between digits are ignored
+func validateLuhn(match string) bool {
+	for _, number := range cardNumberRegex.FindAllString(match, -1) {
+		var digits []int
+		for _, r := range number {
+			if r >= '0' && r <= '9' {
+				digits = append(digits, int(r-'0'))
+			}
+		}
+
+		var sum int
+		for idx := len(digits) - 1; idx >= 0; idx-- {
+			digit := digits[idx]
+			if (len(digits)-idx)%2 == 0 {
+				if digit *= 2; digit > 9 {
+					digit -= 9
+				}
+			}
+			sum += digit
+		}
+
+		if sum%10 == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateSlackToken checks segments of Slack tokens: numeric identifiers followed by a secret part
+func validateSlackToken(match string) bool {
+	for _, token := range slackTokenRegex.FindAllString(match, -1) {
+		segments := strings.Split(token, "-")
+		if len(segments) < 3 || len(segments) > 6 {
+			continue
+		}
+
+		valid := true
+		for _, segment := range segments[1 : len(segments)-1] {
+			if len(segment) == 0 || strings.Trim(segment, "0123456789") != "" {
+				valid = false
+				break
+			}
+		}
+
+		if secret := segments[len(segments)-1]; valid && len(secret) >= 24 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAWSAccessKey checks that an access key ID uses base32 alphabet after its prefix
+func validateAWSAccessKey(match string) bool {
+	for _, key := range awsKeyRegex.FindAllString(match, -1) {
+		if strings.Trim(key[4:], "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// shannonEntropy returns the Shannon entropy of a string in bits per character
+func shannonEntropy(s string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+
+	counts := map[rune]int{}
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		freq := float64(count) / float64(total)
+		entropy -= freq * math.Log2(freq)
+	}
+	return entropy
+}
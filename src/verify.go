@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	verificationVerified = "verified"
+	verificationInvalid  = "invalid"
+	verificationUnknown  = "unknown"
+)
+
+// placeholder in verifier templates replaced with a found secret
+const secretPlaceholder = "{{secret}}"
+
+// maximum size of a response body read when verifying a secret
+const maxVerifierResponse = 1 << 20
+
+// Verifier describes an HTTP request checking whether a secret is live and how to interpret its response
+type Verifier struct {
+	Method        string            `yaml:"method,omitempty"`
+	BaseURL       string            `yaml:"base_url"`
+	Path          string            `yaml:"path,omitempty"`
+	Headers       map[string]string `yaml:"headers,omitempty"`
+	Username      string            `yaml:"username,omitempty"`
+	Password      string            `yaml:"password,omitempty"`
+	Body          string            `yaml:"body,omitempty"`
+	SuccessStatus []int             `yaml:"success_status,omitempty"`
+	SuccessBody   string            `yaml:"success_body,omitempty"`
+	InvalidStatus []int             `yaml:"invalid_status,omitempty"`
+	InvalidBody   string            `yaml:"invalid_body,omitempty"`
+
+	successBody *regexp.Regexp
+	invalidBody *regexp.Regexp
+}
+
+// compile compiles regexes matching response bodies, it is called when patterns are loaded
+func (v *Verifier) compile() error {
+	var err error
+
+	if len(v.SuccessBody) > 0 {
+		if v.successBody, err = regexp.Compile(v.SuccessBody); err != nil {
+			return fmt.Errorf("invalid success_body regex %q: %s", v.SuccessBody, err.Error())
+		}
+	}
+	if len(v.InvalidBody) > 0 {
+		if v.invalidBody, err = regexp.Compile(v.InvalidBody); err != nil {
+			return fmt.Errorf("invalid invalid_body regex %q: %s", v.InvalidBody, err.Error())
+		}
+	}
+	return nil
+}
+
+func containsStatus(statuses []int, status int) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// request builds an HTTP request for a secret, baseURL overrides the one defined by the verifier
+func (v *Verifier) request(secret string, baseURL string) (*http.Request, error) {
+	if len(baseURL) == 0 {
+		baseURL = v.BaseURL
+	}
+
+	method := v.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+
+	target := strings.TrimRight(baseURL, "/") + strings.ReplaceAll(v.Path, secretPlaceholder, url.PathEscape(secret))
+	if _, err := url.Parse(target); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if len(v.Body) > 0 {
+		body = strings.NewReader(strings.ReplaceAll(v.Body, secretPlaceholder, secret))
+	}
+
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range v.Headers {
+		req.Header.Set(name, strings.ReplaceAll(value, secretPlaceholder, secret))
+	}
+
+	if len(v.Username) > 0 || len(v.Password) > 0 {
+		req.SetBasicAuth(strings.ReplaceAll(v.Username, secretPlaceholder, secret), strings.ReplaceAll(v.Password, secretPlaceholder, secret))
+	}
+	return req, nil
+}
+
+// evaluate interprets a response of a verification request
+func (v *Verifier) evaluate(status int, body string) string {
+	successStatus := v.SuccessStatus
+	if len(successStatus) == 0 {
+		successStatus = []int{http.StatusOK}
+	}
+
+	invalidStatus := v.InvalidStatus
+	if len(invalidStatus) == 0 {
+		invalidStatus = []int{http.StatusUnauthorized, http.StatusForbidden}
+	}
+
+	if v.invalidBody != nil && v.invalidBody.MatchString(body) {
+		return verificationInvalid
+	}
+
+	if containsStatus(successStatus, status) {
+		if v.successBody == nil || v.successBody.MatchString(body) {
+			return verificationVerified
+		}
+		return verificationUnknown
+	}
+
+	if containsStatus(invalidStatus, status) {
+		return verificationInvalid
+	}
+	return verificationUnknown
+}
+
+// Verify executes the verification request for a secret
+func (v *Verifier) Verify(client *http.Client, secret string, baseURL string) (string, error) {
+	req, err := v.request(secret, baseURL)
+	if err != nil {
+		return verificationUnknown, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return verificationUnknown, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxVerifierResponse))
+	if err != nil {
+		return verificationUnknown, err
+	}
+	return v.evaluate(resp.StatusCode, string(body)), nil
+}
+
+// VerifySecrets checks found secrets, whose patterns define verifiers, against live endpoints.
+// Requests are rate limited and verification is disabled by default.
+func (app *App) VerifySecrets(scans []*ScanResults) {
+	var verified, total int
+
+	if !*app.verifyFlg {
+		return
+	}
+
+	if *app.verifyRate < 1 {
+		log.Printf("[!!] Provided verification rate %d is not valid. Defaulting to 1 request per second.\n", *app.verifyRate)
+		*app.verifyRate = 1
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	limiter := time.NewTicker(time.Second / time.Duration(*app.verifyRate))
+	defer limiter.Stop()
+
+	defer timer("[+] Finished verifying secrets in")()
+	fmt.Printf("[*] Verifying secrets with at most %d requests per second\n", *app.verifyRate)
+
+	for _, scan := range scans {
+		for line, secret := range scan.secrets {
+			if secret.Pattern == nil || secret.Pattern.Verifier == nil {
+				continue
+			}
+
+			<-limiter.C
+
+			status, err := secret.Pattern.Verifier.Verify(client, secret.SecretValue, *app.verifyURL)
+			if err != nil {
+				log.Printf("[!!] Verification of %s secret in %s failed due to error: %s\n", secret.SecretType, scan.file, err.Error())
+			}
+
+			secret.SetAttribute("verification", status)
+			switch status {
+			case verificationVerified:
+				secret.Severity = severityHigh
+				verified++
+			case verificationInvalid:
+				secret.Severity = severityLow
+			}
+			scan.secrets[line] = secret
+			total++
+		}
+	}
+
+	fmt.Printf("[+] Verified %d out of %d checked secrets as live\n", verified, total)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slackVerifier mimics the Slack verifier from the README, the stand-in server replaces https://slack.com
+func slackVerifier(t *testing.T) *Verifier {
+	v := &Verifier{
+		Method:      http.MethodPost,
+		BaseURL:     "https://slack.com",
+		Path:        "/api/auth.test",
+		Headers:     map[string]string{"Authorization": "Bearer {{secret}}"},
+		SuccessBody: `"ok":\s*true`,
+		InvalidBody: `"ok":\s*false`,
+	}
+	if err := v.compile(); err != nil {
+		t.Fatalf("verifier cannot be compiled: %s", err.Error())
+	}
+	return v
+}
+
+func TestVerifierEvaluate(t *testing.T) {
+	v := slackVerifier(t)
+	statusOnly := &Verifier{}
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		status   int
+		body     string
+		want     string
+	}{
+		{name: "success body", verifier: v, status: http.StatusOK, body: `{"ok": true}`, want: verificationVerified},
+		{name: "invalid body with status 200", verifier: v, status: http.StatusOK, body: `{"ok":false}`, want: verificationInvalid},
+		{name: "success status without success body", verifier: v, status: http.StatusOK, body: `{}`, want: verificationUnknown},
+		{name: "invalid status", verifier: v, status: http.StatusUnauthorized, body: ``, want: verificationInvalid},
+		{name: "rate limited", verifier: v, status: http.StatusTooManyRequests, body: `rate limited`, want: verificationUnknown},
+		{name: "default success status", verifier: statusOnly, status: http.StatusOK, body: ``, want: verificationVerified},
+		{name: "default invalid status", verifier: statusOnly, status: http.StatusForbidden, body: ``, want: verificationInvalid},
+		{name: "server error", verifier: statusOnly, status: http.StatusInternalServerError, body: ``, want: verificationUnknown},
+	}
+
+	for _, test := range tests {
+		if got := test.verifier.evaluate(test.status, test.body); got != test.want {
+			t.Errorf("%s: evaluate(%d, %q) = %s, want %s", test.name, test.status, test.body, got, test.want)
+		}
+	}
+}
+
+func TestVerifierVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/auth.test" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Header.Get("Authorization") {
+		case "Bearer xoxb-live":
+			_, _ = w.Write([]byte(`{"ok": true, "team": "test"}`))
+		case "Bearer xoxb-revoked":
+			_, _ = w.Write([]byte(`{"ok": false, "error": "invalid_auth"}`))
+		case "Bearer xoxb-busy":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	v := slackVerifier(t)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	tests := []struct {
+		secret string
+		want   string
+	}{
+		{secret: "xoxb-live", want: verificationVerified},
+		{secret: "xoxb-revoked", want: verificationInvalid},
+		{secret: "xoxb-busy", want: verificationUnknown},
+		{secret: "xoxb-unknown", want: verificationInvalid},
+	}
+
+	for _, test := range tests {
+		got, err := v.Verify(client, test.secret, server.URL)
+		if err != nil {
+			t.Errorf("Verify(%q) failed: %s", test.secret, err.Error())
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Verify(%q) = %s, want %s", test.secret, got, test.want)
+		}
+	}
+}
+
+func TestVerifierVerifyNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	baseURL := server.URL
+	server.Close()
+
+	got, err := slackVerifier(t).Verify(&http.Client{Timeout: 5 * time.Second}, "xoxb-live", baseURL)
+	if err == nil {
+		t.Errorf("Verify against a closed server succeeded, want an error")
+	}
+	if got != verificationUnknown {
+		t.Errorf("Verify against a closed server = %s, want %s", got, verificationUnknown)
+	}
+}
+
+func TestVerifierInvalidRegexRejected(t *testing.T) {
+	patterns := &Patterns{patterns: []Pattern{{
+		Name:       "Slack Token",
+		Regex:      "xox[pborsa]-[0-9a-z-]+",
+		Confidence: severityHigh,
+		Verifier:   &Verifier{BaseURL: "https://slack.com", SuccessBody: `"ok":\s*(true`},
+	}}}
+
+	err := patterns.compile()
+	if err == nil || !strings.Contains(err.Error(), "success_body") {
+		t.Errorf("compile() = %v, want an error about success_body", err)
+	}
+}
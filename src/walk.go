@@ -0,0 +1,200 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ancestor is a directory on the path from a root, it is tracked only when symlinks are followed
+type ancestor struct {
+	key    fileKey
+	parent *ancestor
+}
+
+// contains checks whether a directory is on the path from a root
+func (a *ancestor) contains(key fileKey) bool {
+	for ; a != nil; a = a.parent {
+		if a.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// walker walks a directory tree applying exclusions, file system boundaries, a depth limit and following of symlinks,
+// with more than one walker directories are walked concurrently and found and progress have to be goroutine safe
+type walker struct {
+	options  *FileListOptions
+	device   uint64            // device of the root directory
+	mutex    sync.Mutex        // guards visited
+	visited  map[fileKey]bool  // directories already walked, tracked only when symlinks are followed
+	found    func(path string) // called for every regular file
+	progress func()            // called for every visited entry
+	slots    chan struct{}     // bounds the number of additional goroutines, nil for the sequential walker
+	wg       sync.WaitGroup
+}
+
+func newWalker(options *FileListOptions, found func(path string), progress func()) *walker {
+	w := &walker{options: options, visited: map[fileKey]bool{}, found: found, progress: progress}
+	if options.walkers > 1 {
+		w.slots = make(chan struct{}, options.walkers-1)
+	}
+	return w
+}
+
+// walk walks a root directory
+func (w *walker) walk(root string) {
+	info, err := os.Stat(root)
+	if err != nil {
+		w.options.skipped.addError(root, err)
+		return
+	}
+	if key, ok := fileKeyOf(info); ok {
+		w.device = key.device
+	}
+	w.directory(root, info, 0, nil)
+	w.wg.Wait()
+}
+
+// visit records a directory reached via symlinks, false is returned when it was already walked
+func (w *walker) visit(key fileKey) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.visited[key] {
+		return false
+	}
+	w.visited[key] = true
+	return true
+}
+
+// entry handles a directory entry found at a depth below the root
+func (w *walker) entry(path string, entry fs.DirEntry, depth int, parent *ancestor) {
+	w.options.guard.wait()
+	w.progress()
+
+	var info fs.FileInfo
+	mode := entry.Type()
+	if mode&fs.ModeSymlink != 0 {
+		if !w.options.followSymlinks {
+			return
+		}
+		var err error
+		if info, err = os.Stat(path); err != nil {
+			w.options.skipped.addError(path, err)
+			return
+		}
+		mode = info.Mode().Type()
+
+		// exclusions apply to targets of symlinks as well, otherwise excluded directories are reachable via symlinks
+		if target, err := filepath.EvalSymlinks(path); err == nil && isExcludedRegEx(target, w.options.excludes) {
+			w.options.skipped.add(path, skipExcluded)
+			return
+		}
+	}
+
+	switch {
+	case mode.IsDir():
+		w.directory(path, info, depth, parent)
+	case mode.IsRegular():
+		w.file(path, entry, info)
+	}
+}
+
+// file passes a regular file to detection of its type unless it is too large or another link of it was found
+func (w *walker) file(path string, entry fs.DirEntry, info fs.FileInfo) {
+	if info == nil {
+		var err error
+		if info, err = entry.Info(); err != nil {
+			w.options.skipped.addError(path, err)
+			return
+		}
+	}
+
+	// sizes are checked before reading files to detect their types
+	if w.options.tooLarge(info.Size()) {
+		w.options.skipped.add(path, skipTooLarge)
+		return
+	}
+
+	// hard linked files are scanned once, other links become aliases of the first found link. Any file can be reached
+	// via symlinks, so every file is recorded when they are followed.
+	if hardLinks(info) > 1 || w.options.followSymlinks {
+		if key, ok := fileKeyOf(info); ok && !w.options.aliases.addLink(key, path) {
+			return
+		}
+	}
+	w.options.aliases.addSize(path, info.Size())
+	w.found(path)
+}
+
+// directory walks a directory unless it is excluded, info is nil when the directory was not reached via a symlink
+func (w *walker) directory(path string, info fs.FileInfo, depth int, parent *ancestor) {
+	if isExcludedRegEx(path, w.options.excludes) {
+		w.options.skipped.add(path, skipExcluded)
+		return
+	}
+
+	if info == nil {
+		var err error
+		if info, err = os.Lstat(path); err != nil {
+			w.options.skipped.addError(path, err)
+			return
+		}
+	}
+
+	if key, ok := fileKeyOf(info); ok {
+		// roots are walked even when they are pseudo file systems, since they were requested explicitly
+		if _, pseudo := w.options.pseudo[key.device]; pseudo && depth > 0 {
+			w.options.skipped.add(path, skipPseudoFS)
+			return
+		}
+		if w.options.oneFilesystem && key.device != w.device {
+			w.options.skipped.add(path, skipOtherFS)
+			return
+		}
+
+		if w.options.followSymlinks {
+			if parent.contains(key) {
+				w.options.skipped.add(path, skipSymlinkLoop)
+				return
+			}
+			// a directory reached via several symlinks is walked once
+			if !w.visit(key) {
+				return
+			}
+			parent = &ancestor{key: key, parent: parent}
+		}
+	}
+
+	if w.options.maxDepth > 0 && depth >= w.options.maxDepth {
+		w.options.skipped.add(path, skipMaxDepth)
+		return
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		w.options.skipped.addError(path, err)
+	}
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+
+		// subdirectories are walked by new goroutines while their number is below the limit, otherwise in place
+		if w.slots != nil && entry.IsDir() {
+			select {
+			case w.slots <- struct{}{}:
+				w.wg.Add(1)
+				go func(entry fs.DirEntry) {
+					defer w.wg.Done()
+					defer func() { <-w.slots }()
+					w.entry(child, entry, depth+1, parent)
+				}(entry)
+				continue
+			default:
+			}
+		}
+		w.entry(child, entry, depth+1, parent)
+	}
+}
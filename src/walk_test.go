@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// walkTree creates a directory tree with excluded and too deep directories, a symlink loop, a symlink to a file,
+// hard links and enough directories to be walked by several goroutines
+func walkTree(t *testing.T) string {
+	root := t.TempDir()
+
+	files := []string{"top.txt", "a/one.txt", "a/b/two.txt", "a/b/c/d/deep.txt", "excluded/secret.txt"}
+	for idx := 0; idx < 20; idx++ {
+		files = append(files, fmt.Sprintf("d%02d/file.txt", idx), fmt.Sprintf("d%02d/sub/file.txt", idx))
+	}
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Link(filepath.Join(root, "top.txt"), filepath.Join(root, "a", "hard.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(root, filepath.Join(root, "a", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "a", "b", "two.txt"), filepath.Join(root, "tolink")); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// walkResult holds files found by a walker together with their hard links and skipped paths
+type walkResult struct {
+	found   int
+	links   []string // found files and their other hard links
+	skipped []SkippedFile
+}
+
+func walkWith(root string, walkers int) walkResult {
+	options := &FileListOptions{
+		excludes:       []string{`\/excluded(\/|$)`},
+		skipped:        &Skips{},
+		aliases:        newAliases(),
+		followSymlinks: true,
+		maxDepth:       3,
+		walkers:        walkers,
+	}
+
+	var mutex sync.Mutex
+	var found []string
+	newWalker(options, func(path string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		found = append(found, path)
+	}, func() {}).walk(root)
+
+	result := walkResult{found: len(found), skipped: options.skipped.Get()}
+	for _, path := range found {
+		result.links = append(append(result.links, path), options.aliases.Get(path)...)
+	}
+	sort.Strings(result.links)
+	return result
+}
+
+func TestWalkersFindSameFiles(t *testing.T) {
+	root := walkTree(t)
+
+	// the symlink to a file is an alias of the file like its hard link
+	sequential := walkWith(root, 1)
+	if sequential.found != 43 {
+		t.Errorf("sequential walker found %d files, want 43", sequential.found)
+	}
+	if len(sequential.links) != 45 {
+		t.Errorf("sequential walker found %d files and links, want 45", len(sequential.links))
+	}
+
+	want := []SkippedFile{
+		{Path: filepath.Join(root, "a", "b", "c"), Reason: skipMaxDepth},
+		{Path: filepath.Join(root, "a", "loop"), Reason: skipSymlinkLoop},
+		{Path: filepath.Join(root, "excluded"), Reason: skipExcluded},
+	}
+	if !reflect.DeepEqual(sequential.skipped, want) {
+		t.Errorf("sequential walker skipped %+v, want %+v", sequential.skipped, want)
+	}
+
+	// goroutines finish in any order, so results are compared over several walks
+	for run := 0; run < 5; run++ {
+		concurrent := walkWith(root, 8)
+		if concurrent.found != sequential.found {
+			t.Errorf("concurrent walker found %d files, sequential walker %d", concurrent.found, sequential.found)
+		}
+		if !reflect.DeepEqual(concurrent.links, sequential.links) {
+			t.Errorf("concurrent walker found %v, sequential walker %v", concurrent.links, sequential.links)
+		}
+		if !reflect.DeepEqual(concurrent.skipped, sequential.skipped) {
+			t.Errorf("concurrent walker skipped %+v, sequential walker %+v", concurrent.skipped, sequential.skipped)
+		}
+	}
+}
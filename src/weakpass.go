@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"crypto/des"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/GehirnInc/crypt"
+	_ "github.com/GehirnInc/crypt/apr1_crypt"
+	_ "github.com/GehirnInc/crypt/md5_crypt"
+	_ "github.com/GehirnInc/crypt/sha256_crypt"
+	_ "github.com/GehirnInc/crypt/sha512_crypt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/md4"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+)
+
+// default credentials tried against every discovered password hash
+var defaultPasswords = []string{
+	"", "password", "Password", "password1", "Password1", "P@ssw0rd", "passw0rd", "123456", "12345678",
+	"123456789", "1234", "12345", "qwerty", "letmein", "welcome", "changeme", "secret", "default",
+	"admin", "administrator", "root", "toor", "guest", "test", "user", "oracle", "postgres", "mysql",
+	"ubnt", "cisco", "nokia", "alcatel", "system", "manager", "support", "service", "pass", "abc123",
+}
+
+// WeakPassword describes an account whose password was found in a list of weak or default passwords
+type WeakPassword struct {
+	File     string
+	Line     int   // 0 when the line number is unknown
+	Offset   int64 // byte offset of the line, set only when its line number is unknown
+	key      int   // key of the secret in results of a scan of the file
+	Account  string
+	HashType string
+	Password string
+	Source   string // list the password was taken from
+}
+
+type candidate struct {
+	password string
+	source   string
+}
+
+// loadWordlist reads passwords from a file, one password per line
+func loadWordlist(file string) ([]string, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fd.Close() }()
+
+	var words []string
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		words = append(words, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	return words, scanner.Err()
+}
+
+// candidates returns passwords to be tried for an account, most likely ones first
+func candidates(account string, wordlist []string) []candidate {
+	var list []candidate
+	if len(account) > 0 {
+		for _, pw := range []string{account, account + "123", account + "1", strings.ToUpper(account[:1]) + account[1:]} {
+			list = append(list, candidate{password: pw, source: "account name"})
+		}
+	}
+	for _, pw := range defaultPasswords {
+		list = append(list, candidate{password: pw, source: "default credentials"})
+	}
+	for _, pw := range wordlist {
+		list = append(list, candidate{password: pw, source: "wordlist"})
+	}
+	return list
+}
+
+// newPasswordVerifier returns a function checking a password against a hash, nil if the hash type is not supported
+func newPasswordVerifier(ph *PasswordHash) func(password string) bool {
+	switch ph.Type {
+	case "md5crypt", "apr1 (Apache MD5)", "sha256crypt", "sha512crypt":
+		if !crypt.IsHashSupported(ph.Hash) {
+			return nil
+		}
+		crypter := crypt.NewFromHash(ph.Hash)
+		return func(password string) bool {
+			return crypter.Verify(ph.Hash, []byte(password)) == nil
+		}
+	case "bcrypt":
+		return func(password string) bool {
+			return bcrypt.CompareHashAndPassword([]byte(ph.Hash), []byte(password)) == nil
+		}
+	case "PBKDF2", "PBKDF2 (Django)":
+		return newPBKDF2Verifier(ph.Hash)
+	case "SHA1 (htpasswd)":
+		return func(password string) bool {
+			sum := sha1.Sum([]byte(password))
+			return "{SHA}"+base64.StdEncoding.EncodeToString(sum[:]) == ph.Hash
+		}
+	case "NTLM":
+		return func(password string) bool {
+			return strings.EqualFold(ntlmHash(password), ph.Hash)
+		}
+	case "LM":
+		lm, _, _ := strings.Cut(ph.Hash, ":")
+		return func(password string) bool {
+			return strings.EqualFold(lmHash(password), lm)
+		}
+	}
+	return nil
+}
+
+// newPBKDF2Verifier supports passlib ($pbkdf2-sha256$rounds$salt$hash) and Django (pbkdf2_sha256$rounds$salt$hash) formats
+func newPBKDF2Verifier(encoded string) func(password string) bool {
+	var digest func() hash.Hash
+	var salt, sum []byte
+	var err error
+
+	fields := strings.Split(strings.TrimPrefix(encoded, "$"), "$")
+	if len(fields) != 4 {
+		return nil
+	}
+
+	iterations, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil
+	}
+
+	switch fields[0] {
+	case "pbkdf2", "pbkdf2_sha1":
+		digest = sha1.New
+	case "pbkdf2-sha256", "pbkdf2_sha256":
+		digest = sha256.New
+	case "pbkdf2-sha512":
+		digest = sha512.New
+	default:
+		return nil
+	}
+
+	if strings.HasPrefix(encoded, "$") {
+		// passlib uses adapted base64 with '.' instead of '+' and without padding
+		ab64 := base64.RawStdEncoding
+		salt, err = ab64.DecodeString(strings.ReplaceAll(fields[2], ".", "+"))
+		if err == nil {
+			sum, err = ab64.DecodeString(strings.ReplaceAll(fields[3], ".", "+"))
+		}
+	} else {
+		salt = []byte(fields[2])
+		sum, err = base64.StdEncoding.DecodeString(fields[3])
+	}
+	if err != nil || len(sum) == 0 {
+		return nil
+	}
+
+	return func(password string) bool {
+		key := pbkdf2.Key([]byte(password), salt, iterations, len(sum), digest)
+		return subtle.ConstantTimeCompare(key, sum) == 1
+	}
+}
+
+func ntlmHash(password string) string {
+	h := md4.New()
+	for _, r := range utf16.Encode([]rune(password)) {
+		_ = binary.Write(h, binary.LittleEndian, r)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lmHash computes LanMan hash, passwords longer than 14 characters cannot have LM hash
+func lmHash(password string) string {
+	if len(password) > 14 {
+		return ""
+	}
+
+	var pw [14]byte
+	copy(pw[:], strings.ToUpper(password))
+
+	var sum []byte
+	for half := 0; half < 2; half++ {
+		block, err := des.NewCipher(lmDESKey(pw[half*7 : half*7+7]))
+		if err != nil {
+			return ""
+		}
+		out := make([]byte, 8)
+		block.Encrypt(out, []byte("KGS!@#$%"))
+		sum = append(sum, out...)
+	}
+	return hex.EncodeToString(sum)
+}
+
+// lmDESKey spreads 56 bits of a 7 byte key over 8 bytes of a DES key
+func lmDESKey(in []byte) []byte {
+	key := make([]byte, 8)
+	key[0] = in[0]
+	key[1] = in[0]<<7 | in[1]>>1
+	key[2] = in[1]<<6 | in[2]>>2
+	key[3] = in[2]<<5 | in[3]>>3
+	key[4] = in[3]<<4 | in[4]>>4
+	key[5] = in[4]<<3 | in[5]>>5
+	key[6] = in[5]<<2 | in[6]>>6
+	key[7] = in[6] << 1
+	return key
+}
+
+// CheckPasswords tries weak and default passwords against password hashes found in scanned files
+func (app *App) CheckPasswords(scans []*ScanResults) []WeakPassword {
+	var wordlist []string
+	var err error
+	var weakPasswords []WeakPassword
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	if !*app.weakPassFlg && len(*app.wordlistFile) == 0 {
+		return nil
+	}
+
+	if len(*app.wordlistFile) > 0 {
+		if wordlist, err = loadWordlist(*app.wordlistFile); err != nil {
+			log.Printf("[!!] Wordlist %s cannot be loaded due to error: %s. Using default credentials only.\n", *app.wordlistFile, err.Error())
+		}
+	}
+
+	type job struct {
+		scan *ScanResults
+		line int
+	}
+	jobs := make(chan job, *app.maxNumberOfCpu)
+
+	defer timer("[+] Finished checking password hashes in")()
+	fmt.Printf("[*] Checking password hashes against %d weak and default passwords\n", len(defaultPasswords)+len(wordlist))
+
+	for cnt := 0; cnt < *app.maxNumberOfCpu; cnt++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range jobs {
+				secret := j.scan.secrets[j.line]
+				verify := newPasswordVerifier(secret.Hash)
+				if verify == nil {
+					continue
+				}
+
+				for _, c := range candidates(secret.Hash.Account, wordlist) {
+					if !*app.forceFlg {
+						app.limiter.Wait()
+					}
+
+					if verify(c.password) {
+						mutex.Lock()
+						weakPasswords = append(weakPasswords, WeakPassword{File: j.scan.file, Line: secret.LineNumber, Offset: secret.Offset, key: j.line, Account: secret.Hash.Account, HashType: secret.Hash.Type, Password: c.password, Source: c.source})
+						mutex.Unlock()
+						break
+					}
+				}
+			}
+		}()
+	}
+
+	for _, scan := range scans {
+		for line, secret := range scan.secrets {
+			if secret.Hash != nil {
+				jobs <- job{scan: scan, line: line}
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// hashes are cracked in any order, weak passwords are sorted to report them in the same order in every run
+	sort.Slice(weakPasswords, func(i, j int) bool {
+		a, b := weakPasswords[i], weakPasswords[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Offset < b.Offset
+	})
+
+	// mark secrets with weak passwords
+	for _, wp := range weakPasswords {
+		for _, scan := range scans {
+			if scan.file == wp.File {
+				secret := scan.secrets[wp.key]
+				secret.SetAttribute("weak password", wp.Source)
+				secret.Severity = severityHigh
+				scan.secrets[wp.key] = secret
+			}
+		}
+	}
+
+	fmt.Printf("[+] Found %d accounts with weak or default passwords\n", len(weakPasswords))
+	return weakPasswords
+}